@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"wtw/internal/configset"
+	"wtw/internal/worktree"
+)
+
+var configSetCmd = &cobra.Command{
+	Use:   "config-set <file> path.to.key=VALUE [path.to.key=VALUE ...]",
+	Short: "Set keys in a JSON, YAML or TOML file",
+	Long: `Set keys in a JSON, YAML or TOML file, chosen by its extension.
+
+Keys are dotted paths; a number selects an element of an existing array, and
+\. is a dot inside a key. Keys that do not exist are created, along with the
+objects or tables leading to them:
+
+  wtw config-set appsettings.json Logging.LogLevel.Default=Debug
+  wtw config-set config.toml server.port=3001 --type int
+  wtw config-set docker-compose.override.yml 'services.web.ports=["3001:3000"]' --type json
+
+JSON and TOML files are edited in place: only the values being set change,
+and comments and layout are kept. YAML files keep their comments and key
+order but are re-indented and may have values requoted; files with several
+YAML documents are refused.
+
+Values are strings unless --type says otherwise.`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: runConfigSet,
+}
+
+func init() {
+	configSetCmd.Flags().String("type", "string", "type of the values: "+configset.Types)
+	rootCmd.AddCommand(configSetCmd)
+}
+
+func runConfigSet(cmd *cobra.Command, args []string) error {
+	typ, _ := cmd.Flags().GetString("type")
+	return worktree.ConfigSet(worktree.ConfigSetConfig{
+		File:  args[0],
+		Pairs: args[1:],
+		Type:  typ,
+	})
+}
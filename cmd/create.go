@@ -4,10 +4,14 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/spf13/cobra"
 
+	"wtw/internal/config"
 	"wtw/internal/git"
+	"wtw/internal/ports"
 	"wtw/internal/worktree"
 )
 
@@ -40,13 +44,112 @@ func runCreate(cmd *cobra.Command, args []string) error {
 	}
 
 	originalDir, _ := os.Getwd()
+	shell, _ := cmd.Flags().GetBool("shell")
+	background, _ := cmd.Flags().GetBool("background")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	track, _ := cmd.Flags().GetBool("track")
+	atomic := config.Bool(repoRoot, "atomic", false)
+	if cmd.Flags().Changed("atomic") {
+		atomic, _ = cmd.Flags().GetBool("atomic")
+	}
+
+	var open *worktree.OpenConfig
+	if after := config.Get(repoRoot, "openAfterCreate"); after != "" {
+		mode, err := worktree.ParseOpenMode(after)
+		if err != nil {
+			return fmt.Errorf("wtw.openAfterCreate: %w", err)
+		}
+		cfg := openConfig(repoRoot, mode)
+		open = &cfg
+	}
+
+	timeout, err := setupTimeout(cmd, "setup-timeout", repoRoot)
+	if err != nil {
+		return err
+	}
+
+	clone, err := cloneConfig(repoRoot)
+	if err != nil {
+		return err
+	}
+
+	portsCfg, err := portsConfig(repoRoot)
+	if err != nil {
+		return err
+	}
 
 	return worktree.Create(worktree.CreateConfig{
-		BranchName:  branchName,
-		BaseDir:     baseDir,
-		SetupScript: setupScript,
-		RepoRoot:    repoRoot,
-		RepoName:    filepath.Base(repoRoot),
-		OriginalDir: originalDir,
+		BranchName:   branchName,
+		BaseDir:      baseDir,
+		SetupScript:  setupScript,
+		RepoRoot:     repoRoot,
+		RepoName:     filepath.Base(repoRoot),
+		OriginalDir:  originalDir,
+		Open:         open,
+		Shell:        shell,
+		Background:   background,
+		SetupTimeout: timeout,
+		Interpreter:  config.Get(repoRoot, "interpreter"),
+		SetupInputs:  config.GetAll(repoRoot, "setupInput"),
+		Steps:        stepSelection(cmd),
+		Clone:        clone,
+		Ports:        portsCfg,
+		DryRun:       dryRun,
+		Track:        track,
+		Atomic:       atomic,
 	})
 }
+
+// cloneConfig builds the dependency-cloning settings from git config, or nil
+// when wtw.cloneDeps is false.
+func cloneConfig(repoRoot string) (*worktree.CloneConfig, error) {
+	if !config.Bool(repoRoot, "cloneDeps", true) {
+		return nil, nil
+	}
+	cfg := &worktree.CloneConfig{
+		Dirs:     worktree.DefaultDepDirs,
+		Fallback: config.Get(repoRoot, "cloneFallback"),
+	}
+	if entries := config.GetAll(repoRoot, "clone"); entries != nil {
+		cfg.Dirs = nil
+		for _, e := range entries {
+			d, err := worktree.ParseDepDir(e)
+			if err != nil {
+				return nil, fmt.Errorf("wtw.clone: %w", err)
+			}
+			cfg.Dirs = append(cfg.Dirs, d)
+		}
+	}
+	return cfg, nil
+}
+
+// portsConfig builds the port allocation settings from git config, or nil
+// when wtw.ports is false.
+func portsConfig(repoRoot string) (*ports.Config, error) {
+	if !config.Bool(repoRoot, "ports", true) {
+		return nil, nil
+	}
+	cfg := &ports.Config{Min: ports.DefaultMin, Max: ports.DefaultMax, BlockSize: ports.DefaultBlockSize}
+	if r := config.Get(repoRoot, "portRange"); r != "" {
+		lo, hi, err := ports.ParseRange(r)
+		if err != nil {
+			return nil, fmt.Errorf("wtw.portRange: %w", err)
+		}
+		cfg.Min, cfg.Max = lo, hi
+	}
+	if n := config.Get(repoRoot, "portBlockSize"); n != "" {
+		size, err := strconv.Atoi(n)
+		if err != nil || size < 1 {
+			return nil, fmt.Errorf("wtw.portBlockSize: invalid value %q", n)
+		}
+		cfg.BlockSize = size
+	}
+	if names := config.Get(repoRoot, "portNames"); names != "" {
+		for _, n := range strings.Split(names, ",") {
+			if n = strings.TrimSpace(n); n != "" {
+				cfg.Names = append(cfg.Names, n)
+			}
+		}
+	}
+	return cfg, nil
+}
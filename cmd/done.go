@@ -20,13 +20,15 @@ func init() {
 	rootCmd.AddCommand(doneCmd)
 }
 
-func runDone(_ *cobra.Command, _ []string) error {
+func runDone(cmd *cobra.Command, _ []string) error {
 	worktreeRoot, mainRepoRoot, err := git.RequireWorktree("done")
 	if err != nil {
 		return err
 	}
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
 	return worktree.Remove(worktree.RemoveConfig{
 		WorktreeRoot: worktreeRoot,
 		MainRepoRoot: mainRepoRoot,
+		DryRun:       dryRun,
 	})
 }
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"wtw/internal/worktree"
+)
+
+var envGetCmd = &cobra.Command{
+	Use:   "env-get <file> KEY",
+	Short: "Print the value of a key in an env file",
+	Long: `Print the value of a key in an env file, unquoted and unescaped.
+
+Exits non-zero when the key is not set, unless --default is given:
+
+  DB="$(wtw env-get .env DB_DATABASE --default app)"`,
+	Args: cobra.ExactArgs(2),
+	RunE: runEnvGet,
+}
+
+func init() {
+	envGetCmd.Flags().String("default", "", "value to print when the key is not set")
+	rootCmd.AddCommand(envGetCmd)
+}
+
+func runEnvGet(cmd *cobra.Command, args []string) error {
+	def, _ := cmd.Flags().GetString("default")
+	return worktree.EnvGet(worktree.EnvGetConfig{
+		File:       args[0],
+		Key:        args[1],
+		Default:    def,
+		HasDefault: cmd.Flags().Changed("default"),
+	})
+}
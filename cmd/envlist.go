@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"wtw/internal/worktree"
+)
+
+var envListCmd = &cobra.Command{
+	Use:   "env-list <file>",
+	Short: "List the keys and values of an env file",
+	Long: `List the keys of an env file with their values, as KEY=VALUE lines in the
+order they appear (quoted where needed), or as a JSON object with --json.
+A key assigned more than once is listed with its last value.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runEnvList,
+}
+
+func init() {
+	envListCmd.Flags().Bool("json", false, "print a JSON object")
+	rootCmd.AddCommand(envListCmd)
+}
+
+func runEnvList(cmd *cobra.Command, args []string) error {
+	asJSON, _ := cmd.Flags().GetBool("json")
+	return worktree.EnvList(worktree.EnvListConfig{File: args[0], JSON: asJSON})
+}
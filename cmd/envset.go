@@ -1,8 +1,12 @@
 package cmd
 
 import (
+	"os"
+
 	"github.com/spf13/cobra"
 
+	"wtw/internal/git"
+	"wtw/internal/interp"
 	"wtw/internal/worktree"
 )
 
@@ -11,19 +15,56 @@ var envSetCmd = &cobra.Command{
 	Short: "Set or add key-value pairs in an env file",
 	Long: `Set or add key-value pairs in an env file.
 
-Existing keys are updated in-place; keys that do not exist are appended at
-the end. Comment lines and blank lines are left unchanged.`,
+Existing keys are updated in place, keeping their export prefix, inline
+comment and quoting; keys that do not exist are appended at the end. Values
+with spaces, quotes or # are quoted. The rest of the file is left unchanged,
+byte for byte.
+
+Values are written as given. With --mode expand, ${NAME} in a value is
+replaced with a key already in the file, a worktree variable such as
+$BRANCH_NAME or $WTW_PORT, or an environment variable, in that order, and
+filters make identifiers out of values:
+
+  wtw env-set --mode expand .env 'DB_DATABASE=${BRANCH_NAME|snake|max:63}'
+  wtw env-set --mode expand .env 'DB_URL=postgres://localhost:${WTW_PORT}/${DB_DATABASE}'
+
+Filters are lower, upper, snake, kebab, dns, db (see 'wtw slug'), max:N
+and default:TEXT; $${ is a literal ${. Undefined variables expand to
+nothing; --mode strict makes them an error.`,
 	Args: cobra.MinimumNArgs(2),
 	RunE: runEnvSet,
 }
 
 func init() {
+	envSetCmd.Flags().String("mode", "literal", "how to treat ${...} in values: "+interp.Modes)
 	rootCmd.AddCommand(envSetCmd)
 }
 
-func runEnvSet(_ *cobra.Command, args []string) error {
+func runEnvSet(cmd *cobra.Command, args []string) error {
+	name, _ := cmd.Flags().GetString("mode")
+	mode, err := interp.ParseMode(name)
+	if err != nil {
+		return err
+	}
 	return worktree.EnvSet(worktree.EnvSetConfig{
 		File:  args[0],
 		Pairs: args[1:],
+		Mode:  mode,
+		Vars:  worktreeVars(),
 	})
 }
+
+// worktreeVars returns the setup variables of the current worktree, or
+// nothing outside a git repository.
+func worktreeVars() []string {
+	worktreePath, err := git.RepoRoot()
+	if err != nil {
+		return nil
+	}
+	mainRepoRoot, err := git.MainRepoRoot()
+	if err != nil {
+		return nil
+	}
+	cwd, _ := os.Getwd()
+	return worktree.WorktreeEnv(worktreePath, mainRepoRoot, cwd)
+}
@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"wtw/internal/worktree"
+)
+
+var envUnsetCmd = &cobra.Command{
+	Use:   "env-unset <file> KEY [KEY ...]",
+	Short: "Remove keys from an env file",
+	Long: `Remove keys from an env file. Every assignment of a key is removed; keys
+that are not set are ignored. The rest of the file is left unchanged.`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: runEnvUnset,
+}
+
+func init() {
+	rootCmd.AddCommand(envUnsetCmd)
+}
+
+func runEnvUnset(_ *cobra.Command, args []string) error {
+	return worktree.EnvUnset(worktree.EnvUnsetConfig{
+		File: args[0],
+		Keys: args[1:],
+	})
+}
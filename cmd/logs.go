@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"wtw/internal/worktree"
+)
+
+var logsCmd = &cobra.Command{
+	Use:   "logs [branch]",
+	Short: "Show the output of a worktree's last setup run",
+	Long: `Show the output of the last .wtwrc or .wtw.yml run in a worktree. Every
+setup run is logged to .git/worktrees/<name>/wtw/ together with a JSON record
+of its exit code, duration, script hash and the variables it was given; the
+last 10 runs are kept. Without a branch, uses the current worktree.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runLogs,
+}
+
+func init() {
+	logsCmd.Flags().BoolP("follow", "f", false, "keep printing output until the run finishes")
+	logsCmd.Flags().BoolP("list", "l", false, "list all recorded runs")
+	rootCmd.AddCommand(logsCmd)
+}
+
+func runLogs(cmd *cobra.Command, args []string) error {
+	worktreePath, err := worktreeFromArgs(args)
+	if err != nil {
+		return err
+	}
+	follow, _ := cmd.Flags().GetBool("follow")
+	list, _ := cmd.Flags().GetBool("list")
+	return worktree.Logs(worktree.LogsConfig{WorktreePath: worktreePath, Follow: follow, List: list})
+}
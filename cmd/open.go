@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"wtw/internal/config"
+	"wtw/internal/git"
+	"wtw/internal/worktree"
+)
+
+var openCmd = &cobra.Command{
+	Use:     "open [branch]",
+	Aliases: []string{"o"},
+	Short:   "Open a worktree in an editor, tmux or a new terminal",
+	Long: `Open a worktree in an editor, tmux or a new terminal.
+
+Without a branch, opens the current worktree. The editor defaults to
+$VISUAL or $EDITOR; both the editor and terminal commands can be overridden
+with command templates that see the same variables as .wtwrc:
+
+  git config --global wtw.editor 'code "$WORKTREE_PATH"'
+  git config --global wtw.terminal 'kitty --directory "$WORKTREE_PATH"'
+
+To open every new worktree automatically after creation:
+
+  git config --global wtw.openAfterCreate editor   # or tmux, terminal`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runOpen,
+}
+
+func init() {
+	openCmd.Flags().Bool("editor", false, "open in $VISUAL/$EDITOR or wtw.editor (default)")
+	openCmd.Flags().Bool("tmux", false, "open a tmux window or session named after the worktree")
+	openCmd.Flags().Bool("terminal", false, "open a new terminal window")
+	openCmd.MarkFlagsMutuallyExclusive("editor", "tmux", "terminal")
+	rootCmd.AddCommand(openCmd)
+}
+
+func runOpen(cmd *cobra.Command, args []string) error {
+	mainRepoRoot, err := git.MainRepoRoot()
+	if err != nil {
+		return fmt.Errorf("not inside a git repository")
+	}
+
+	var worktreePath, branchName string
+	if len(args) > 0 {
+		branchName = args[0]
+		worktreePath = git.WorktreeForBranch(mainRepoRoot, branchName)
+		if worktreePath == "" {
+			return fmt.Errorf("no worktree for branch %q", branchName)
+		}
+	} else {
+		if worktreePath, err = git.RepoRoot(); err != nil {
+			return err
+		}
+		branchName = git.CurrentBranch(worktreePath)
+	}
+
+	mode := worktree.OpenEditor
+	if tmux, _ := cmd.Flags().GetBool("tmux"); tmux {
+		mode = worktree.OpenTmux
+	} else if terminal, _ := cmd.Flags().GetBool("terminal"); terminal {
+		mode = worktree.OpenTerminal
+	}
+
+	cfg := openConfig(mainRepoRoot, mode)
+	cfg.WorktreePath = worktreePath
+	cfg.BranchName = branchName
+	cfg.RepoRoot = mainRepoRoot
+	cfg.OriginalDir, _ = os.Getwd()
+	return worktree.Open(cfg)
+}
+
+// openConfig returns an OpenConfig for mode with the command templates from
+// git config filled in.
+func openConfig(repoRoot string, mode worktree.OpenMode) worktree.OpenConfig {
+	return worktree.OpenConfig{
+		Mode:        mode,
+		EditorCmd:   config.Get(repoRoot, "editor"),
+		TerminalCmd: config.Get(repoRoot, "terminal"),
+	}
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"wtw/internal/git"
+	"wtw/internal/ports"
+)
+
+var portCmd = &cobra.Command{
+	Use:   "port [branch] [name]",
+	Short: "Show the ports allocated to a worktree",
+	Long: `Show the ports allocated to a worktree.
+
+Every worktree gets a stable block of ports when it is created, exposed to
+.wtwrc as $WTW_PORT, $WTW_PORT_1, ... and $WTW_PORT_<NAME> for names set in
+wtw.portNames. With a name (or index), prints just that port, for scripts:
+
+  npm run dev -- --port "$(wtw port web)"
+
+Without a branch, uses the current worktree. Use --all to list every
+allocation on this machine.`,
+	Args: cobra.MaximumNArgs(2),
+	RunE: runPort,
+}
+
+func init() {
+	portCmd.Flags().BoolP("all", "a", false, "list allocations of all worktrees")
+	rootCmd.AddCommand(portCmd)
+}
+
+func runPort(cmd *cobra.Command, args []string) error {
+	if all, _ := cmd.Flags().GetBool("all"); all {
+		allocs, err := ports.All()
+		if err != nil {
+			return err
+		}
+		for _, a := range allocs {
+			fmt.Printf("%d-%d  %s  %s\n", a.Ports[0], a.Ports[len(a.Ports)-1], a.Branch, a.Worktree)
+		}
+		return nil
+	}
+
+	mainRepoRoot, err := git.MainRepoRoot()
+	if err != nil {
+		return fmt.Errorf("not inside a git repository")
+	}
+
+	// A single argument is a branch if it has a worktree, otherwise a port
+	// name in the current worktree.
+	var worktreePath, name string
+	switch {
+	case len(args) == 2:
+		worktreePath, name = git.WorktreeForBranch(mainRepoRoot, args[0]), args[1]
+		if worktreePath == "" {
+			return fmt.Errorf("no worktree for branch %q", args[0])
+		}
+	case len(args) == 1 && git.WorktreeForBranch(mainRepoRoot, args[0]) != "":
+		worktreePath = git.WorktreeForBranch(mainRepoRoot, args[0])
+	default:
+		if worktreePath, err = git.RepoRoot(); err != nil {
+			return err
+		}
+		if len(args) == 1 {
+			name = args[0]
+		}
+	}
+
+	a, ok := ports.Lookup(worktreePath)
+	if !ok {
+		return fmt.Errorf("no ports allocated for %s", worktreePath)
+	}
+	if name != "" {
+		p, err := a.Port(name)
+		if err != nil {
+			return err
+		}
+		fmt.Println(p)
+		return nil
+	}
+	for i, p := range a.Ports {
+		label := strconv.Itoa(i)
+		if i < len(a.Names) {
+			label = a.Names[i]
+		}
+		fmt.Printf("%-8s %d\n", label, p)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"wtw/internal/prompt"
+)
+
+var promptCmd = &cobra.Command{
+	Use:   "prompt",
+	Short: "Print a short prompt segment for the current worktree",
+	Long: `Print a short prompt segment such as "wt:feature-login*" for the current
+worktree, or nothing outside linked worktrees.
+
+It reads git metadata directly instead of running git, so it is cheap enough
+for PS1. Placeholders in --format: {branch}, {name}, {path}, {dirty}.
+{dirty} is "*" when tracked files changed, or "?" when the check did not
+finish within --timeout.
+
+  PS1='$(wtw prompt) \$ '`,
+	Args: cobra.NoArgs,
+	RunE: runPrompt,
+}
+
+func init() {
+	promptCmd.Flags().StringP("format", "f", prompt.DefaultFormat, "segment format")
+	promptCmd.Flags().Duration("timeout", 50*time.Millisecond, "time budget for the dirty check")
+	rootCmd.AddCommand(promptCmd)
+}
+
+func runPrompt(cmd *cobra.Command, _ []string) error {
+	format, _ := cmd.Flags().GetString("format")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil
+	}
+	if seg := prompt.Segment(cwd, format, timeout); seg != "" {
+		fmt.Print(seg)
+	}
+	return nil
+}
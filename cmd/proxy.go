@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"wtw/internal/config"
+	"wtw/internal/git"
+	"wtw/internal/proxy"
+)
+
+var proxyCmd = &cobra.Command{
+	Use:   "proxy",
+	Short: "Route <worktree>.localhost to each worktree's dev server",
+	Long: `Run a local HTTP reverse proxy that routes <worktree-name>.localhost to the
+port allocated to that worktree (see 'wtw port'). Routes follow the port
+registry, so worktrees created or removed while the proxy runs are picked up
+automatically. WebSocket upgrades are proxied too. Open the proxy's own
+address for a status page listing all routes.
+
+Settings can be given as flags or in git config:
+
+  git config --global wtw.proxyListen 127.0.0.1:8080
+  git config --global wtw.proxySuffix test        # myapp-feature-x.test
+  git config --global wtw.proxyPort web           # route to $WTW_PORT_WEB`,
+	Args: cobra.NoArgs,
+	RunE: runProxy,
+}
+
+func init() {
+	proxyCmd.Flags().String("listen", "", "address to listen on (default 127.0.0.1:8080)")
+	proxyCmd.Flags().String("suffix", "", "hostname suffix (default localhost)")
+	proxyCmd.Flags().String("port-name", "", "name or index of the port to route to (default the first)")
+	rootCmd.AddCommand(proxyCmd)
+}
+
+func runProxy(cmd *cobra.Command, _ []string) error {
+	// Settings may come from global git config, so a repo is optional here.
+	repoRoot, _ := git.MainRepoRoot()
+	if repoRoot == "" {
+		repoRoot, _ = os.Getwd()
+	}
+	setting := func(flag, key, def string) string {
+		if v, _ := cmd.Flags().GetString(flag); v != "" {
+			return v
+		}
+		if v := config.Get(repoRoot, key); v != "" {
+			return v
+		}
+		return def
+	}
+	listen := setting("listen", "proxyListen", "127.0.0.1:8080")
+
+	srv, err := proxy.New(proxy.Config{
+		Suffix:   setting("suffix", "proxySuffix", "localhost"),
+		PortName: setting("port-name", "proxyPort", ""),
+	})
+	if err != nil {
+		return err
+	}
+
+	printRoutes := func() {
+		fmt.Printf("Routes (%s):\n", time.Now().Format("15:04:05"))
+		for _, r := range srv.Routes() {
+			fmt.Printf("  http://%s → 127.0.0.1:%d\n", r.Host, r.Port)
+		}
+	}
+	printRoutes()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go srv.Watch(ctx, time.Second, printRoutes)
+
+	server := &http.Server{Addr: listen, Handler: srv, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Printf("Proxy listening on http://%s\n", listen)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"wtw/internal/worktree"
+)
+
+var resumeCmd = &cobra.Command{
+	Use:   "resume [branch]",
+	Short: "Finish creating a worktree whose creation failed or was interrupted",
+	Long: `Continue the creation of a worktree from its first step that did not
+finish: the checkout, the .worktreeinclude copy, the dependency clone or the
+setup. A declarative setup file picks up at the step that failed; a
+.wtwrc.d directory at the script that failed. Without a branch, uses the
+current worktree.
+
+'wtw list' marks worktrees whose creation is incomplete.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runResume,
+}
+
+func init() {
+	rootCmd.AddCommand(resumeCmd)
+}
+
+func runResume(cmd *cobra.Command, args []string) error {
+	worktreePath, err := worktreeFromArgs(args)
+	if err != nil {
+		return err
+	}
+	return worktree.Resume(worktree.ResumeConfig{WorktreePath: worktreePath})
+}
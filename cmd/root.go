@@ -3,9 +3,12 @@
 package cmd
 
 import (
+	"fmt"
+
 	"github.com/spf13/cobra"
 
 	"wtw/internal/update"
+	"wtw/internal/worktree"
 )
 
 // rootCmd is the default action: `wtw [branch]` creates a worktree.
@@ -20,15 +23,30 @@ Optionally runs a .wtwrc setup script in the new worktree.`,
 	Args:          cobra.MaximumNArgs(2),
 	SilenceUsage:  true,
 	SilenceErrors: true,
-	PersistentPreRun: func(cmd *cobra.Command, _ []string) {
-		if cmd.Name() == "update" {
-			return
+	PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+		// A dry run must not change anything, so commands that can't honour
+		// it refuse rather than ignore it; nor does it offer updates.
+		if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+			if !dryRunCommands[cmd.Name()] {
+				return fmt.Errorf("%s does not support --dry-run", cmd.CommandPath())
+			}
+			return nil
+		}
+		// prompt runs on every shell prompt and must never block on the network.
+		// setup-worker runs detached, with nobody to answer.
+		switch cmd.Name() {
+		case "update", "prompt", "setup-worker":
+			return nil
 		}
 		update.MaybeAutoCheckAndPrompt(appVersion)
+		return nil
 	},
 	RunE: runCreate,
 }
 
+// dryRunCommands are the commands that support --dry-run.
+var dryRunCommands = map[string]bool{"wtw": true, "done": true, "run-wtwrc": true}
+
 var appVersion = "dev"
 
 // SetVersion sets the version string shown by --version.
@@ -44,4 +62,12 @@ func Execute() error {
 
 func init() {
 	rootCmd.PersistentFlags().StringP("setup", "c", "", "path to a setup script to run in the new worktree")
+	rootCmd.PersistentFlags().Bool("dry-run", false, "print what would be done without changing anything")
+	rootCmd.Flags().Bool("shell", false, "start a subshell in the new worktree")
+	rootCmd.Flags().BoolP("background", "b", false, "run the setup script in the background (see 'wtw status')")
+	rootCmd.Flags().String("setup-timeout", "", "stop the setup script after this long, e.g. 10m (default wtw.setupTimeout)")
+	rootCmd.Flags().Bool("track", false, "start a branch that only exists on a remote from the remote branch, tracking it")
+	rootCmd.Flags().Bool("atomic", false, "undo everything if creation or setup fails or is interrupted (default wtw.atomic)")
+	rootCmd.Flags().StringSlice("only", nil, "run only these steps of "+worktree.StepsFileName)
+	rootCmd.Flags().StringSlice("skip", nil, "skip these steps of "+worktree.StepsFileName)
 }
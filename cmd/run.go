@@ -1,11 +1,14 @@
 package cmd
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/spf13/cobra"
 
+	"wtw/internal/config"
 	"wtw/internal/git"
 	"wtw/internal/worktree"
 )
@@ -13,19 +16,49 @@ import (
 var runCmd = &cobra.Command{
 	Use:     "run-wtwrc",
 	Aliases: []string{"rrc"},
-	Short:   "Re-run the setup script in the current worktree",
-	Args:    cobra.NoArgs,
-	RunE:    runSetup,
+	Short:   "Re-run the setup script or .wtw.yml in the current worktree",
+	Long: `Re-run the setup script or .wtw.yml in the current worktree.
+
+With --if-changed the run is skipped when the setup file and its inputs
+(wtw.setupInput, lockfiles by default) are unchanged since the last
+successful run. --install-hook makes git do that after every pull.`,
+	Args: cobra.NoArgs,
+	RunE: runSetup,
 }
 
 func init() {
 	runCmd.Flags().StringP("setup", "c", "", "path to a setup script to run")
+	runCmd.Flags().StringSlice("only", nil, "run only these steps of "+worktree.StepsFileName)
+	runCmd.Flags().StringSlice("skip", nil, "skip these steps of "+worktree.StepsFileName)
+	runCmd.Flags().String("timeout", "", "stop the setup after this long, e.g. 10m (default wtw.setupTimeout)")
+	runCmd.Flags().Bool("if-changed", false, "skip if nothing changed since the last successful run")
+	runCmd.Flags().Bool("force", false, "run even if --if-changed finds nothing changed")
+	runCmd.Flags().Bool("install-hook", false, "re-run setup with --if-changed after every git pull")
+	runCmd.Flags().Bool("auto", false, "run from a git hook")
+	_ = runCmd.Flags().MarkHidden("auto")
 	rootCmd.AddCommand(runCmd)
 }
 
 func runSetup(cmd *cobra.Command, _ []string) error {
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	if install, _ := cmd.Flags().GetBool("install-hook"); install {
+		if dryRun {
+			return fmt.Errorf("--install-hook does not support --dry-run")
+		}
+		repoRoot, err := git.MainRepoRoot()
+		if err != nil {
+			return fmt.Errorf("not inside a git repository")
+		}
+		return worktree.InstallSetupHooks(repoRoot)
+	}
+
+	auto, _ := cmd.Flags().GetBool("auto")
 	worktreeRoot, mainRepoRoot, err := git.RequireWorktree("run-wtwrc")
 	if err != nil {
+		if auto {
+			// Hooks also fire in the main repo, which has no setup to re-run.
+			return nil
+		}
 		return err
 	}
 
@@ -39,11 +72,50 @@ func runSetup(cmd *cobra.Command, _ []string) error {
 	}
 
 	originalDir, _ := os.Getwd()
+	timeout, err := setupTimeout(cmd, "timeout", mainRepoRoot)
+	if err != nil {
+		return err
+	}
+	ifChanged, _ := cmd.Flags().GetBool("if-changed")
+	force, _ := cmd.Flags().GetBool("force")
 
 	return worktree.RunSetup(worktree.RunSetupConfig{
 		SetupScript:  customSetup,
 		WorktreeRoot: worktreeRoot,
 		MainRepoRoot: mainRepoRoot,
 		OriginalDir:  originalDir,
+		Steps:        stepSelection(cmd),
+		Timeout:      timeout,
+		Interpreter:  config.Get(mainRepoRoot, "interpreter"),
+		Inputs:       config.GetAll(mainRepoRoot, "setupInput"),
+		IfChanged:    ifChanged,
+		Force:        force,
+		Auto:         auto,
+		DryRun:       dryRun,
 	})
 }
+
+// stepSelection reads the --only/--skip flags.
+func stepSelection(cmd *cobra.Command) worktree.StepSelection {
+	only, _ := cmd.Flags().GetStringSlice("only")
+	skip, _ := cmd.Flags().GetStringSlice("skip")
+	return worktree.StepSelection{Only: only, Skip: skip}
+}
+
+// setupTimeout reads a setup timeout from flag, falling back to
+// wtw.setupTimeout. Zero means no limit.
+func setupTimeout(cmd *cobra.Command, flag, repoRoot string) (time.Duration, error) {
+	v, _ := cmd.Flags().GetString(flag)
+	source := "--" + flag
+	if v == "" {
+		v, source = config.Get(repoRoot, "setupTimeout"), "wtw.setupTimeout"
+	}
+	if v == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%s: invalid duration %q (e.g. 90s, 10m)", source, v)
+	}
+	return d, nil
+}
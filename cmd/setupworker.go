@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"wtw/internal/worktree"
+)
+
+// setupWorkerCmd is started detached by `wtw <branch> --background`; it is
+// not meant to be run by hand.
+var setupWorkerCmd = &cobra.Command{
+	Use:    "setup-worker",
+	Hidden: true,
+	Args:   cobra.NoArgs,
+	RunE:   runSetupWorker,
+}
+
+func init() {
+	setupWorkerCmd.Flags().String("run", "", "id of the setup run to resume")
+	setupWorkerCmd.Flags().String("setup", "", "setup script to run")
+	setupWorkerCmd.Flags().String("worktree", "", "worktree to run it in")
+	setupWorkerCmd.Flags().String("branch", "", "branch of the worktree")
+	setupWorkerCmd.Flags().String("repo", "", "main repo root")
+	setupWorkerCmd.Flags().String("original-dir", "", "directory wtw was called from")
+	setupWorkerCmd.Flags().Duration("timeout", 0, "stop the setup after this long")
+	setupWorkerCmd.Flags().String("interpreter", "", "interpreter for the setup script")
+	setupWorkerCmd.Flags().StringArray("input", nil, "file the setup depends on")
+	setupWorkerCmd.Flags().StringSlice("only", nil, "")
+	setupWorkerCmd.Flags().StringSlice("skip", nil, "")
+	rootCmd.AddCommand(setupWorkerCmd)
+}
+
+func runSetupWorker(cmd *cobra.Command, _ []string) error {
+	flag := func(name string) string {
+		v, _ := cmd.Flags().GetString(name)
+		return v
+	}
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	inputs, _ := cmd.Flags().GetStringArray("input")
+	return worktree.RunBackgroundSetup(worktree.BackgroundSetupConfig{
+		SetupScript:  flag("setup"),
+		WorktreePath: flag("worktree"),
+		BranchName:   flag("branch"),
+		RepoRoot:     flag("repo"),
+		OriginalDir:  flag("original-dir"),
+		Steps:        stepSelection(cmd),
+		Timeout:      timeout,
+		Interpreter:  flag("interpreter"),
+		Inputs:       inputs,
+		RunID:        flag("run"),
+	})
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"wtw/internal/git"
+	"wtw/internal/worktree"
+)
+
+var shellCmd = &cobra.Command{
+	Use:     "shell [branch]",
+	Aliases: []string{"sh"},
+	Short:   "Start a subshell inside a worktree",
+	Long: `Start $SHELL inside a worktree. Exit the shell to return to where you were.
+
+The shell gets the same variables as .wtwrc ($WORKTREE_PATH, $BRANCH_NAME,
+$REPO_ROOT, ...) plus $WTW_WORKTREE, which prompts can use to show the
+worktree name. Without a branch, uses the current worktree.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runShell,
+}
+
+func init() {
+	rootCmd.AddCommand(shellCmd)
+}
+
+func runShell(_ *cobra.Command, args []string) error {
+	mainRepoRoot, err := git.MainRepoRoot()
+	if err != nil {
+		return fmt.Errorf("not inside a git repository")
+	}
+
+	var worktreePath, branchName string
+	if len(args) > 0 {
+		branchName = args[0]
+		worktreePath = git.WorktreeForBranch(mainRepoRoot, branchName)
+		if worktreePath == "" {
+			return fmt.Errorf("no worktree for branch %q", branchName)
+		}
+	} else {
+		if worktreePath, err = git.RepoRoot(); err != nil {
+			return err
+		}
+		branchName = git.CurrentBranch(worktreePath)
+	}
+
+	originalDir, _ := os.Getwd()
+	return worktree.Shell(worktree.ShellConfig{
+		WorktreePath: worktreePath,
+		BranchName:   branchName,
+		RepoRoot:     mainRepoRoot,
+		OriginalDir:  originalDir,
+	})
+}
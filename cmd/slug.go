@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"wtw/internal/git"
+	"wtw/internal/slug"
+)
+
+var slugCmd = &cobra.Command{
+	Use:   "slug [branch]",
+	Short: "Print a safe identifier derived from a branch name",
+	Long: `Print an identifier derived from a branch name, for the places .wtwrc
+needs one. Without a branch, uses the current worktree's branch.
+
+Styles:
+  dir    letters, digits, '.', '_' and '-', as in worktree directory names
+  snake  lower case with '_', e.g. Redis key prefixes
+  kebab  lower case with '-', e.g. Docker Compose project names
+  dns    a DNS label: kebab, at most 63 characters
+  db     a database name: snake, not starting with a digit, at most 63 characters
+
+A slug longer than --max (or the style's limit) is cut short and ends with a
+hash of the branch name, so long branches that start alike still differ.
+Shorter slugs are not hashed: branches that differ only in case or
+punctuation, like feat/x and feat-x, share one.
+
+  wtw env-set .env DB_DATABASE="$(wtw slug --style db)"`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runSlug,
+}
+
+func init() {
+	slugCmd.Flags().String("style", "dir", "dir, snake, kebab, dns or db")
+	slugCmd.Flags().Int("max", 0, "maximum length (default: the style's limit, if any)")
+	rootCmd.AddCommand(slugCmd)
+}
+
+func runSlug(cmd *cobra.Command, args []string) error {
+	name, _ := cmd.Flags().GetString("style")
+	style, err := slug.ParseStyle(name)
+	if err != nil {
+		return err
+	}
+	maxLen, _ := cmd.Flags().GetInt("max")
+
+	var branch string
+	if len(args) == 1 {
+		branch = args[0]
+	} else {
+		worktreePath, err := git.RepoRoot()
+		if err != nil {
+			return fmt.Errorf("not inside a git repository")
+		}
+		if branch = git.CurrentBranch(worktreePath); branch == "" {
+			return errors.New("not on a branch; pass one")
+		}
+	}
+
+	s, err := slug.Make(branch, style, maxLen)
+	if err != nil {
+		return err
+	}
+	fmt.Println(s)
+	return nil
+}
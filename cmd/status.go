@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"wtw/internal/git"
+	"wtw/internal/worktree"
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status [branch]",
+	Short: "Show the state of a worktree's background setup",
+	Long: `Show whether the setup started by 'wtw <branch> --background' is running,
+succeeded or failed, with the tail of its log. Without a branch, uses the
+current worktree.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runStatus,
+}
+
+func init() {
+	statusCmd.Flags().IntP("lines", "n", 10, "number of log lines to show")
+	rootCmd.AddCommand(statusCmd)
+}
+
+func runStatus(cmd *cobra.Command, args []string) error {
+	worktreePath, err := worktreeFromArgs(args)
+	if err != nil {
+		return err
+	}
+	lines, _ := cmd.Flags().GetInt("lines")
+	return worktree.Status(worktree.SetupStatusConfig{WorktreePath: worktreePath, Lines: lines})
+}
+
+// worktreeFromArgs returns the worktree of the branch in args[0], or the
+// current worktree when no branch is given.
+func worktreeFromArgs(args []string) (string, error) {
+	mainRepoRoot, err := git.MainRepoRoot()
+	if err != nil {
+		return "", fmt.Errorf("not inside a git repository")
+	}
+	if len(args) == 0 {
+		return git.RepoRoot()
+	}
+	path := git.WorktreeForBranch(mainRepoRoot, args[0])
+	if path == "" {
+		return "", fmt.Errorf("no worktree for branch %q", args[0])
+	}
+	return path, nil
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"wtw/internal/git"
+	"wtw/internal/worktree"
+)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync [branch ...]",
+	Short: "Fetch and update worktrees onto their base branch",
+	Long: `Fetch once, then bring worktrees up to date.
+
+Branches that track an upstream are fast-forwarded. Branches created by wtw
+are rebased (or merged with --merge) onto the branch they were created from.
+Worktrees with uncommitted changes are skipped. On a conflict the rebase or
+merge is aborted and the run stops.
+
+Without arguments, syncs the current worktree.`,
+	RunE: runSync,
+}
+
+func init() {
+	syncCmd.Flags().Bool("rebase", false, "rebase branches onto their base (default)")
+	syncCmd.Flags().Bool("merge", false, "merge the base into branches instead of rebasing")
+	syncCmd.Flags().BoolP("all", "a", false, "sync every worktree of the repo")
+	syncCmd.MarkFlagsMutuallyExclusive("rebase", "merge")
+	rootCmd.AddCommand(syncCmd)
+}
+
+func runSync(cmd *cobra.Command, args []string) error {
+	mainRepoRoot, err := git.MainRepoRoot()
+	if err != nil {
+		return fmt.Errorf("not inside a git repository")
+	}
+
+	all, _ := cmd.Flags().GetBool("all")
+	if all && len(args) > 0 {
+		return fmt.Errorf("--all cannot be combined with branch names")
+	}
+
+	branches := args
+	if !all && len(branches) == 0 {
+		worktreeRoot, _, err := git.RequireWorktree("sync")
+		if err != nil {
+			return fmt.Errorf("%w (or pass branch names / --all)", err)
+		}
+		branch := git.CurrentBranch(worktreeRoot)
+		if branch == "" {
+			return fmt.Errorf("current worktree is in a detached HEAD state")
+		}
+		branches = []string{branch}
+	}
+
+	mode := worktree.SyncRebase
+	if merge, _ := cmd.Flags().GetBool("merge"); merge {
+		mode = worktree.SyncMerge
+	}
+
+	return worktree.Sync(worktree.SyncConfig{
+		RepoRoot: mainRepoRoot,
+		Branches: branches,
+		All:      all,
+		Mode:     mode,
+	})
+}
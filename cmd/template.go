@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"wtw/internal/git"
+	"wtw/internal/worktree"
+)
+
+var templateCmd = &cobra.Command{
+	Use:   "template <src> <dst>",
+	Short: "Render a template file with the worktree's variables",
+	Long: `Render src to dst with the current worktree's variables: the ones .wtwrc
+gets ($WORKTREE_PATH, $BRANCH_NAME, $REPO_ROOT, $WTW_PORT, ...).
+
+A src ending in .gotmpl is a Go template: {{.BRANCH_NAME}}, {{.WTW_PORT}},
+with the functions env, slug, lower, upper, snake, kebab, dns, db, max and
+default:
+
+  server_name {{.BRANCH_NAME | dns}}.localhost;
+  listen {{.WTW_PORT}};
+
+Anything else has ${VAR} placeholders with the same filters as env-set, and
+{{ is left alone, so Helm or Jinja files are safe to template:
+
+  name: ${BRANCH_NAME|kebab}
+  ports: ["${WTW_PORT}:3000", "${DB_PORT:-5432}:5432"]
+
+Other ${...}, like compose's ${DB_PORT:-5432}, and undefined variables are
+left as written; in a Go template undefined variables render empty. --strict
+makes undefined variables an error. --check renders without writing and
+fails, showing a diff, when dst has drifted.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runTemplate,
+}
+
+func init() {
+	templateCmd.Flags().Bool("strict", false, "fail on undefined variables")
+	templateCmd.Flags().Bool("check", false, "report whether dst differs from the rendered output, writing nothing")
+	rootCmd.AddCommand(templateCmd)
+}
+
+func runTemplate(cmd *cobra.Command, args []string) error {
+	worktreePath, err := git.RepoRoot()
+	if err != nil {
+		return fmt.Errorf("not inside a git repository")
+	}
+	mainRepoRoot, err := git.MainRepoRoot()
+	if err != nil {
+		return err
+	}
+	cwd, _ := os.Getwd()
+	strict, _ := cmd.Flags().GetBool("strict")
+	check, _ := cmd.Flags().GetBool("check")
+	return worktree.Template(worktree.TemplateConfig{
+		Src:          args[0],
+		Dst:          args[1],
+		WorktreePath: worktreePath,
+		RepoRoot:     mainRepoRoot,
+		OriginalDir:  cwd,
+		Strict:       strict,
+		Check:        check,
+	})
+}
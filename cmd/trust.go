@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"wtw/internal/git"
+	"wtw/internal/worktree"
+)
+
+var trustCmd = &cobra.Command{
+	Use:   "trust [script]",
+	Short: "Approve the repo's setup script so wtw may run it",
+	Long: `Approve the current content of the repo's setup script (.wtwrc by default).
+
+wtw remembers a hash of every approved script per repo. When a script that
+lives in the repo is new or has changed since it was approved, wtw shows the
+changes and will not run it until it is trusted again. Non-interactive runs
+refuse untrusted scripts outright.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runTrust,
+}
+
+func init() {
+	trustCmd.Flags().Bool("list", false, "list trusted scripts in all repos")
+	trustCmd.Flags().Bool("revoke", false, "revoke trust for the script, or for every script in this repo")
+	trustCmd.MarkFlagsMutuallyExclusive("list", "revoke")
+	rootCmd.AddCommand(trustCmd)
+}
+
+func runTrust(cmd *cobra.Command, args []string) error {
+	if list, _ := cmd.Flags().GetBool("list"); list {
+		return worktree.ListTrust()
+	}
+
+	repoRoot, err := git.MainRepoRoot()
+	if err != nil {
+		return fmt.Errorf("not inside a git repository")
+	}
+
+	var script string
+	if len(args) > 0 {
+		if script, err = filepath.Abs(args[0]); err != nil {
+			return fmt.Errorf("invalid path: %s", args[0])
+		}
+	}
+
+	cfg := worktree.TrustConfig{RepoRoot: repoRoot, Script: script}
+	if revoke, _ := cmd.Flags().GetBool("revoke"); revoke {
+		return worktree.RevokeTrust(cfg)
+	}
+	return worktree.Trust(cfg)
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"wtw/internal/worktree"
+)
+
+var waitCmd = &cobra.Command{
+	Use:   "wait [branch]",
+	Short: "Wait for a worktree's background setup to finish",
+	Long: `Block until the setup started by 'wtw <branch> --background' finishes.
+Exits non-zero if the setup failed or --timeout passed, so scripts can do:
+
+  wtw feature/x --background
+  ...
+  wtw wait feature/x && npm test`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runWait,
+}
+
+func init() {
+	waitCmd.Flags().Duration("timeout", 0, "give up after this long (e.g. 10m)")
+	waitCmd.Flags().IntP("lines", "n", 10, "number of log lines to show when done")
+	rootCmd.AddCommand(waitCmd)
+}
+
+func runWait(cmd *cobra.Command, args []string) error {
+	worktreePath, err := worktreeFromArgs(args)
+	if err != nil {
+		return err
+	}
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	lines, _ := cmd.Flags().GetInt("lines")
+	return worktree.Wait(worktree.SetupStatusConfig{WorktreePath: worktreePath, Lines: lines, Timeout: timeout})
+}
@@ -0,0 +1,38 @@
+// Package config reads wtw settings from the wtw.* section of git config, so
+// they can be set per repo or for every repo with
+// `git config [--global] wtw.<key> <value>`.
+package config
+
+import (
+	"strings"
+
+	"wtw/internal/git"
+)
+
+// Get returns the value of wtw.<key> as seen from repoRoot, or "" if unset.
+func Get(repoRoot, key string) string {
+	out, err := git.OutputIn(repoRoot, "config", "--get", "wtw."+key)
+	if err != nil {
+		return ""
+	}
+	return out
+}
+
+// GetAll returns every value of a multi-valued wtw.<key>, or nil if unset.
+func GetAll(repoRoot, key string) []string {
+	out, err := git.OutputIn(repoRoot, "config", "--get-all", "wtw."+key)
+	if err != nil || out == "" {
+		return nil
+	}
+	return strings.Split(out, "\n")
+}
+
+// Bool returns wtw.<key> interpreted as a git boolean, or def if unset or
+// not a valid boolean.
+func Bool(repoRoot, key string, def bool) bool {
+	out, err := git.OutputIn(repoRoot, "config", "--type=bool", "--get", "wtw."+key)
+	if err != nil {
+		return def
+	}
+	return out == "true"
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"os/exec"
+	"reflect"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("HOME", dir)
+
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	git("config", "wtw.editor", "code .")
+	git("config", "wtw.flag", "yes")
+	git("config", "--add", "wtw.multi", "a")
+	git("config", "--add", "wtw.multi", "b")
+
+	if got := Get(dir, "editor"); got != "code ." {
+		t.Errorf("Get(editor) = %q", got)
+	}
+	if got := Get(dir, "missing"); got != "" {
+		t.Errorf("Get(missing) = %q, want empty", got)
+	}
+	if got := GetAll(dir, "multi"); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("GetAll(multi) = %v", got)
+	}
+	if !Bool(dir, "flag", false) {
+		t.Error("Bool(flag) = false, want true")
+	}
+	if !Bool(dir, "missing", true) {
+		t.Error("Bool(missing) should return the default")
+	}
+}
@@ -0,0 +1,148 @@
+// Package configset sets keys in JSON, YAML and TOML files, addressed by
+// dotted paths such as logging.level. JSON and TOML are edited in place, so
+// everything but the changed values keeps its formatting and comments; YAML
+// goes through yaml.v3, which keeps comments and key order but normalises
+// indentation and quoting.
+package configset
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Format is a config file format.
+type Format string
+
+const (
+	JSON Format = "json"
+	YAML Format = "yaml"
+	TOML Format = "toml"
+)
+
+// FormatOf returns the format of a file from its extension.
+func FormatOf(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return JSON, nil
+	case ".yaml", ".yml":
+		return YAML, nil
+	case ".toml":
+		return TOML, nil
+	}
+	return "", fmt.Errorf("%s: unknown format; want a .json, .yaml, .yml or .toml file", path)
+}
+
+// Assignment sets the key at Path to Value.
+type Assignment struct {
+	Path  []string
+	Value any // string, int64, bool, or anything decoded from JSON
+}
+
+// Types lists the value types ParseValue accepts.
+const Types = "string, int, bool or json"
+
+// ParseValue converts the text of a value to typ.
+func ParseValue(raw, typ string) (any, error) {
+	switch typ {
+	case "", "string":
+		return raw, nil
+	case "int":
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an int", raw)
+		}
+		return n, nil
+	case "bool":
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a bool", raw)
+		}
+		return b, nil
+	case "json":
+		var v any
+		if err := json.Unmarshal([]byte(raw), &v); err != nil {
+			return nil, fmt.Errorf("%q is not valid JSON: %w", raw, err)
+		}
+		return v, nil
+	}
+	return nil, fmt.Errorf("unknown type %q: want %s", typ, Types)
+}
+
+// ParsePath splits a dotted key path. A backslash escapes a dot that is part
+// of a key, as in "hosts.example\.com".
+func ParsePath(s string) ([]string, error) {
+	var path []string
+	var seg strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && s[i+1] == '.':
+			seg.WriteByte('.')
+			i++
+		case s[i] == '.':
+			path = append(path, seg.String())
+			seg.Reset()
+		default:
+			seg.WriteByte(s[i])
+		}
+	}
+	path = append(path, seg.String())
+	for _, p := range path {
+		if p == "" {
+			return nil, fmt.Errorf("invalid key path %q", s)
+		}
+	}
+	return path, nil
+}
+
+// Set applies the assignments, in order, to the contents of a file.
+func Set(format Format, data []byte, assignments []Assignment) ([]byte, error) {
+	var set func([]byte, Assignment) ([]byte, error)
+	switch format {
+	case JSON:
+		set = setJSON
+	case YAML:
+		set = setYAML
+	case TOML:
+		set = setTOML
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	var err error
+	for _, a := range assignments {
+		if data, err = set(data, a); err != nil {
+			return nil, fmt.Errorf("%s: %w", strings.Join(a.Path, "."), err)
+		}
+	}
+	return data, nil
+}
+
+// errNotTable is returned when a path runs into a value that cannot hold
+// keys.
+var errNotTable = errors.New("is not an object")
+
+// nest wraps value in one object per key of path, innermost last.
+func nest(path []string, value any) any {
+	for i := len(path) - 1; i >= 0; i-- {
+		value = orderedMap{{path[i], value}}
+	}
+	return value
+}
+
+// orderedMap is an object whose keys keep their order when encoded.
+type orderedMap [][2]any
+
+// detectIndent returns the indentation unit of text: the leading whitespace
+// of its first indented line, or two spaces.
+func detectIndent(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed != "" && len(trimmed) < len(line) {
+			return line[:len(line)-len(trimmed)]
+		}
+	}
+	return "  "
+}
@@ -0,0 +1,93 @@
+package configset
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFormatOf(t *testing.T) {
+	for path, want := range map[string]Format{
+		"appsettings.json": JSON,
+		"compose.yaml":     YAML,
+		"config/app.YML":   YAML,
+		"pyproject.toml":   TOML,
+	} {
+		if got, err := FormatOf(path); err != nil || got != want {
+			t.Errorf("FormatOf(%q) = %q, %v; want %q", path, got, err, want)
+		}
+	}
+	if _, err := FormatOf(".env"); err == nil {
+		t.Error("FormatOf(.env) succeeded")
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	cases := map[string][]string{
+		"port":                  {"port"},
+		"server.port":           {"server", "port"},
+		`hosts.example\.com.ip`: {"hosts", "example.com", "ip"},
+		"ports.0":               {"ports", "0"},
+	}
+	for in, want := range cases {
+		if got, err := ParsePath(in); err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("ParsePath(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "a..b", ".a", "a."} {
+		if _, err := ParsePath(in); err == nil {
+			t.Errorf("ParsePath(%q) succeeded", in)
+		}
+	}
+}
+
+func TestParseValue(t *testing.T) {
+	cases := []struct {
+		raw, typ string
+		want     any
+	}{
+		{"3000", "", "3000"},
+		{"3000", "string", "3000"},
+		{"3000", "int", int64(3000)},
+		{"true", "bool", true},
+		{`["a", 1]`, "json", []any{"a", float64(1)}},
+		{`{"a": null}`, "json", map[string]any{"a": nil}},
+	}
+	for _, c := range cases {
+		if got, err := ParseValue(c.raw, c.typ); err != nil || !reflect.DeepEqual(got, c.want) {
+			t.Errorf("ParseValue(%q, %q) = %#v, %v; want %#v", c.raw, c.typ, got, err, c.want)
+		}
+	}
+	for _, c := range [][2]string{{"3.5", "int"}, {"yes", "bool"}, {"{", "json"}, {"1", "float"}} {
+		if _, err := ParseValue(c[0], c[1]); err == nil {
+			t.Errorf("ParseValue(%q, %q) succeeded", c[0], c[1])
+		}
+	}
+}
+
+// set applies path=value pairs of strings, failing the test on error.
+func set(t *testing.T, format Format, in string, pairs ...any) string {
+	t.Helper()
+	var as []Assignment
+	for i := 0; i < len(pairs); i += 2 {
+		path, err := ParsePath(pairs[i].(string))
+		if err != nil {
+			t.Fatal(err)
+		}
+		as = append(as, Assignment{Path: path, Value: pairs[i+1]})
+	}
+	out, err := Set(format, []byte(in), as)
+	if err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	return string(out)
+}
+
+// setErr applies one assignment and returns its error.
+func setErr(format Format, in, path string, value any) error {
+	p, err := ParsePath(path)
+	if err != nil {
+		return err
+	}
+	_, err = Set(format, []byte(in), []Assignment{{Path: p, Value: value}})
+	return err
+}
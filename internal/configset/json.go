@@ -0,0 +1,271 @@
+package configset
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// jsonNode is a value in a JSON document with its byte span.
+type jsonNode struct {
+	start, end int
+	kind       byte // '{', '[', or 0 for anything else
+	members    []jsonMember
+	elems      []*jsonNode
+}
+
+type jsonMember struct {
+	key      string
+	keyStart int
+	value    *jsonNode
+}
+
+// setJSON edits the text of the document, so only the value being set, or
+// the member being added, changes.
+func setJSON(data []byte, a Assignment) ([]byte, error) {
+	// A byte order mark, which some Windows editors write, is not JSON but
+	// is kept.
+	if rest, ok := bytes.CutPrefix(data, utf8BOM); ok {
+		out, err := setJSON(rest, a)
+		if err != nil {
+			return nil, err
+		}
+		return append(append([]byte{}, utf8BOM...), out...), nil
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		data = []byte("{}\n")
+	}
+	if !json.Valid(data) {
+		return nil, errors.New("file is not valid JSON")
+	}
+	indent := detectIndent(string(data))
+	p := &jsonParser{data: data}
+	node := p.value()
+
+	for i, key := range a.Path {
+		last := i == len(a.Path)-1
+		switch node.kind {
+		case '[':
+			n, err := strconv.Atoi(key)
+			if err != nil || n < 0 || n >= len(node.elems) {
+				return nil, fmt.Errorf("%s has no element %s", where(a.Path[:i]), key)
+			}
+			if last {
+				return replaceJSON(data, node.elems[n], a.Value, indent)
+			}
+			node = node.elems[n]
+		case '{':
+			var found *jsonNode
+			for _, m := range node.members {
+				if m.key == key {
+					found = m.value
+				}
+			}
+			if found == nil {
+				return insertJSON(data, node, key, nest(a.Path[i+1:], a.Value), indent)
+			}
+			if last {
+				return replaceJSON(data, found, a.Value, indent)
+			}
+			node = found
+		default:
+			return nil, fmt.Errorf("%s %w", where(a.Path[:i]), errNotTable)
+		}
+	}
+	return data, nil
+}
+
+// where names the value at path in error messages.
+func where(path []string) string {
+	if len(path) == 0 {
+		return "the top level"
+	}
+	return strings.Join(path, ".")
+}
+
+// replaceJSON replaces node with value, indenting a multi-line value to
+// match the line node starts on.
+func replaceJSON(data []byte, node *jsonNode, value any, indent string) ([]byte, error) {
+	enc, err := encodeJSON(value, lineIndent(data, node.start), indent)
+	if err != nil {
+		return nil, err
+	}
+	return splice(data, node.start, node.end, enc), nil
+}
+
+// insertJSON adds key to obj after its last member, following the layout of
+// the object: one member per line, or all on one line.
+func insertJSON(data []byte, obj *jsonNode, key string, value any, indent string) ([]byte, error) {
+	k, err := encodeJSON(key, "", "")
+	if err != nil {
+		return nil, err
+	}
+	multiline := bytes.IndexByte(data[obj.start:obj.end], '\n') >= 0
+	if len(obj.members) == 0 {
+		outer := lineIndent(data, obj.start)
+		enc, err := encodeJSON(value, outer+indent, indent)
+		if err != nil {
+			return nil, err
+		}
+		member := "\n" + outer + indent + string(k) + ": " + string(enc) + "\n" + outer
+		return splice(data, obj.start+1, obj.end-1, []byte(member)), nil
+	}
+
+	last := obj.members[len(obj.members)-1]
+	var member string
+	if multiline {
+		inner := lineIndent(data, last.keyStart)
+		enc, err := encodeJSON(value, inner, indent)
+		if err != nil {
+			return nil, err
+		}
+		member = ",\n" + inner + string(k) + ": " + string(enc)
+	} else {
+		enc, err := encodeJSON(value, "", "")
+		if err != nil {
+			return nil, err
+		}
+		member = ", " + string(k) + ": " + string(enc)
+	}
+	end := last.value.end
+	return splice(data, end, end, []byte(member)), nil
+}
+
+// encodeJSON encodes v without escaping HTML characters. With an indent it
+// is laid out over several lines, each after the first starting with prefix.
+func encodeJSON(v any, prefix, indent string) ([]byte, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	compact := bytes.TrimRight(b.Bytes(), "\n")
+	if indent == "" {
+		return compact, nil
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, compact, prefix, indent); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// MarshalJSON encodes the map with its keys in order.
+func (m orderedMap) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, kv := range m {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		for j, part := range kv {
+			enc, err := encodeJSON(part, "", "")
+			if err != nil {
+				return nil, err
+			}
+			if j == 1 {
+				b.WriteByte(':')
+			}
+			b.Write(enc)
+		}
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// lineIndent returns the leading whitespace of the line containing pos.
+func lineIndent(data []byte, pos int) string {
+	start := bytes.LastIndexByte(data[:pos], '\n') + 1
+	end := start
+	for end < len(data) && (data[end] == ' ' || data[end] == '\t') {
+		end++
+	}
+	return string(data[start:end])
+}
+
+// splice returns data with data[start:end] replaced by repl.
+func splice(data []byte, start, end int, repl []byte) []byte {
+	out := make([]byte, 0, len(data)-(end-start)+len(repl))
+	out = append(out, data[:start]...)
+	out = append(out, repl...)
+	return append(out, data[end:]...)
+}
+
+// jsonParser records the spans of the values in a document already known to
+// be valid JSON.
+type jsonParser struct {
+	data []byte
+	pos  int
+}
+
+func (p *jsonParser) space() {
+	for p.pos < len(p.data) && strings.IndexByte(" \t\r\n", p.data[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+func (p *jsonParser) value() *jsonNode {
+	p.space()
+	n := &jsonNode{start: p.pos}
+	switch p.data[p.pos] {
+	case '{':
+		n.kind = '{'
+		p.pos++
+		for {
+			p.space()
+			if c := p.data[p.pos]; c == '}' || c == ',' {
+				p.pos++
+				if c == '}' {
+					break
+				}
+				continue
+			}
+			keyStart := p.pos
+			p.str()
+			var key string
+			_ = json.Unmarshal(p.data[keyStart:p.pos], &key)
+			p.space()
+			p.pos++ // ':'
+			n.members = append(n.members, jsonMember{key: key, keyStart: keyStart, value: p.value()})
+		}
+	case '[':
+		n.kind = '['
+		p.pos++
+		for {
+			p.space()
+			if c := p.data[p.pos]; c == ']' || c == ',' {
+				p.pos++
+				if c == ']' {
+					break
+				}
+				continue
+			}
+			n.elems = append(n.elems, p.value())
+		}
+	case '"':
+		p.str()
+	default:
+		for p.pos < len(p.data) && strings.IndexByte(",]} \t\r\n", p.data[p.pos]) < 0 {
+			p.pos++
+		}
+	}
+	n.end = p.pos
+	return n
+}
+
+func (p *jsonParser) str() {
+	p.pos++
+	for p.data[p.pos] != '"' {
+		if p.data[p.pos] == '\\' {
+			p.pos++
+		}
+		p.pos++
+	}
+	p.pos++
+}
@@ -0,0 +1,87 @@
+package configset
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSetJSON(t *testing.T) {
+	cases := []struct {
+		name  string
+		in    string
+		pairs []any
+		want  string
+	}{
+		{
+			name:  "replace keeps layout",
+			in:    "{\n  \"name\": \"app\",  \n  \"port\": 3000\n}\n",
+			pairs: []any{"port", int64(4000), "name", "<new>"},
+			want:  "{\n  \"name\": \"<new>\",  \n  \"port\": 4000\n}\n",
+		},
+		{
+			name:  "add to multi-line object",
+			in:    "{\n    \"a\": {\n        \"b\": 1\n    }\n}",
+			pairs: []any{"a.c", true, "d", "x"},
+			want:  "{\n    \"a\": {\n        \"b\": 1,\n        \"c\": true\n    },\n    \"d\": \"x\"\n}",
+		},
+		{
+			name:  "add to one-line object",
+			in:    `{"a": {"b": 1}}`,
+			pairs: []any{"a.c", int64(2)},
+			want:  `{"a": {"b": 1, "c": 2}}`,
+		},
+		{
+			name:  "create nested objects",
+			in:    "{\n  \"a\": {}\n}\n",
+			pairs: []any{"a.b.c", "v"},
+			want:  "{\n  \"a\": {\n    \"b\": {\n      \"c\": \"v\"\n    }\n  }\n}\n",
+		},
+		{
+			name:  "empty file",
+			in:    "",
+			pairs: []any{"a", "v"},
+			want:  "{\n  \"a\": \"v\"\n}\n",
+		},
+		{
+			name:  "array element",
+			in:    `{"ports": [3000, 3001]}`,
+			pairs: []any{"ports.1", int64(4000)},
+			want:  `{"ports": [3000, 4000]}`,
+		},
+		{
+			name:  "replace with object",
+			in:    "{\n\t\"a\": 1\n}",
+			pairs: []any{"a", map[string]any{"x": []any{float64(1), "y"}}},
+			want:  "{\n\t\"a\": {\n\t\t\"x\": [\n\t\t\t1,\n\t\t\t\"y\"\n\t\t]\n\t}\n}",
+		},
+		{
+			name:  "escaped keys",
+			in:    `{"a\"b": 1, "a.b": 2}`,
+			pairs: []any{`a"b`, int64(3), `a\.b`, int64(4)},
+			want:  `{"a\"b": 3, "a.b": 4}`,
+		},
+		{
+			name:  "byte order mark is kept",
+			in:    "\ufeff{\n  \"a\": 1\n}\n",
+			pairs: []any{"a", int64(2), "b", true},
+			want:  "\ufeff{\n  \"a\": 2,\n  \"b\": true\n}\n",
+		},
+	}
+	for _, c := range cases {
+		if got := set(t, JSON, c.in, c.pairs...); got != c.want {
+			t.Errorf("%s:\ngot:\n%s\nwant:\n%s", c.name, got, c.want)
+		}
+	}
+}
+
+func TestSetJSON_Errors(t *testing.T) {
+	if err := setErr(JSON, `{"a": 1}`, "a.b", "x"); !errors.Is(err, errNotTable) {
+		t.Errorf("setting below a number: %v", err)
+	}
+	if err := setErr(JSON, `{"a": [1]}`, "a.1", "x"); err == nil {
+		t.Error("setting past the end of an array succeeded")
+	}
+	if err := setErr(JSON, `{"a": `, "a", "x"); err == nil {
+		t.Error("editing invalid JSON succeeded")
+	}
+}
@@ -0,0 +1,453 @@
+package configset
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// tomlDoc is the layout of a TOML file: where its tables and key/value
+// lines are. Values are not decoded, only located, so they can be replaced
+// without touching the rest of the file.
+type tomlDoc struct {
+	tables  []*tomlTable // tables[0] is the root table
+	entries []tomlEntry
+}
+
+type tomlTable struct {
+	path   []string
+	array  bool   // an array of tables, or a table inside one
+	start  int    // where the header line starts
+	insert int    // where a new key goes: after the last key, or the header
+	indent string // indentation of the last key
+	keys   int
+}
+
+type tomlEntry struct {
+	table              *tomlTable
+	key                []string // dotted key, relative to the table
+	lineStart, lineEnd int
+	valStart, valEnd   int
+}
+
+func (e tomlEntry) path() []string {
+	return append(slices.Clone(e.table.path), e.key...)
+}
+
+// setTOML replaces the value of an existing key in place. A new key goes
+// after the last key of its table, next to the other dotted keys that
+// define its table, or under a new table header at the end of the file.
+func setTOML(data []byte, a Assignment) ([]byte, error) {
+	doc, err := parseTOML(data)
+	if err != nil {
+		return nil, fmt.Errorf("file is not valid TOML: %w", err)
+	}
+	value, err := encodeTOML(a.Value)
+	if err != nil {
+		return nil, err
+	}
+	path, parent := a.Path, a.Path[:len(a.Path)-1]
+	nl := "\n"
+	if bytes.Contains(data, []byte("\r\n")) {
+		nl = "\r\n"
+	}
+
+	for _, t := range doc.tables[1:] {
+		if t.array && hasPrefix(path, t.path) {
+			return nil, fmt.Errorf("%s is in an array of tables, which config-set cannot edit", strings.Join(t.path, "."))
+		}
+		if hasPrefix(t.path, path) {
+			return nil, fmt.Errorf("%s is a table", strings.Join(path, "."))
+		}
+	}
+	for _, e := range doc.entries {
+		if e.table.array {
+			continue
+		}
+		full := e.path()
+		switch {
+		case slices.Equal(full, path):
+			return splice(data, e.valStart, e.valEnd, []byte(value)), nil
+		case hasPrefix(path, full):
+			if data[e.valStart] == '{' {
+				return nil, fmt.Errorf("%s is an inline table, which config-set cannot edit", strings.Join(full, "."))
+			}
+			return nil, fmt.Errorf("%s %w", strings.Join(full, "."), errNotTable)
+		case hasPrefix(full, path):
+			return nil, fmt.Errorf("%s is a table", strings.Join(path, "."))
+		}
+	}
+
+	// A table made by dotted keys cannot be reopened with a header.
+	for i := len(doc.entries) - 1; i >= 0; i-- {
+		e := doc.entries[i]
+		if !e.table.array && len(e.table.path) < len(parent) && hasPrefix(e.path(), parent) {
+			line := lineIndent(data, e.lineStart) + tomlKey(path[len(e.table.path):]) + " = " + value + nl
+			return insertLine(data, e.lineEnd, line, nl), nil
+		}
+	}
+
+	for _, t := range doc.tables {
+		if t.array || !slices.Equal(t.path, parent) {
+			continue
+		}
+		line := t.indent + tomlKey(path[len(parent):]) + " = " + value + nl
+		if t == doc.tables[0] && t.keys == 0 {
+			// A first root key goes above the first table, and the
+			// comments describing it, or else at the end.
+			if len(doc.tables) == 1 {
+				return insertLine(data, len(data), line, nl), nil
+			}
+			pos := commentsAbove(data, doc.tables[1].start)
+			return splice(data, pos, pos, []byte(line+nl)), nil
+		}
+		return insertLine(data, t.insert, line, nl), nil
+	}
+
+	text := ""
+	if len(data) > 0 {
+		if data[len(data)-1] != '\n' {
+			text += nl
+		}
+		if !bytes.HasSuffix(data, []byte("\n\n")) && !bytes.HasSuffix(data, []byte("\n\r\n")) {
+			text += nl
+		}
+	}
+	text += "[" + tomlKey(parent) + "]" + nl + tomlKey(path[len(parent):]) + " = " + value + nl
+	return append(slices.Clone(data), text...), nil
+}
+
+// insertLine inserts line at pos, first ending the last line of the file if
+// pos is the end of a file without a final newline.
+func insertLine(data []byte, pos int, line, nl string) []byte {
+	if pos == len(data) && pos > 0 && data[pos-1] != '\n' {
+		line = nl + line
+	}
+	return splice(data, pos, pos, []byte(line))
+}
+
+// commentsAbove returns the start of the comment lines directly above the
+// line starting at pos, which describe that line.
+func commentsAbove(data []byte, pos int) int {
+	for pos > 0 {
+		start := bytes.LastIndexByte(data[:pos-1], '\n') + 1
+		if !bytes.HasPrefix(bytes.TrimLeft(data[start:pos], " \t"), []byte("#")) {
+			break
+		}
+		pos = start
+	}
+	return pos
+}
+
+func hasPrefix(path, prefix []string) bool {
+	return len(path) >= len(prefix) && slices.Equal(path[:len(prefix)], prefix)
+}
+
+// tomlKey writes a dotted key, quoting the parts that are not bare keys.
+func tomlKey(path []string) string {
+	parts := make([]string, len(path))
+	for i, p := range path {
+		parts[i] = p
+		for j := 0; j < len(p); j++ {
+			if !bareKeyChar(p[j]) {
+				parts[i] = tomlString(p)
+				break
+			}
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+func bareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// tomlString writes s as a TOML basic string.
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// encodeTOML writes v as a TOML value. Objects become inline tables.
+func encodeTOML(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return tomlString(v), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return strconv.FormatInt(int64(v), 10), nil
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case nil:
+		return "", errors.New("TOML has no null value")
+	case []any:
+		parts := make([]string, len(v))
+		for i, e := range v {
+			var err error
+			if parts[i], err = encodeTOML(e); err != nil {
+				return "", err
+			}
+		}
+		return "[" + strings.Join(parts, ", ") + "]", nil
+	case map[string]any:
+		if len(v) == 0 {
+			return "{}", nil
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		parts := make([]string, len(keys))
+		for i, k := range keys {
+			e, err := encodeTOML(v[k])
+			if err != nil {
+				return "", err
+			}
+			parts[i] = tomlKey([]string{k}) + " = " + e
+		}
+		return "{ " + strings.Join(parts, ", ") + " }", nil
+	}
+	return "", fmt.Errorf("cannot write %T as TOML", v)
+}
+
+// parseTOML finds the tables and key/value lines of a TOML file. It checks
+// only as much syntax as it needs to find them.
+func parseTOML(data []byte) (*tomlDoc, error) {
+	cur := &tomlTable{}
+	doc := &tomlDoc{tables: []*tomlTable{cur}}
+	p := &tomlScanner{data: data}
+	for p.pos < len(data) {
+		lineStart := p.pos
+		p.blank()
+		switch c := p.peek(); c {
+		case '\n', '\r', '#', 0:
+			p.skipLine()
+		case '[':
+			array := p.has("[[")
+			p.pos++
+			if array {
+				p.pos++
+			}
+			key, err := p.key()
+			if err != nil {
+				return nil, err
+			}
+			p.blank()
+			if (array && !p.has("]]")) || !p.has("]") {
+				return nil, p.errorf("unterminated table header")
+			}
+			p.skipLine()
+			for _, t := range doc.tables[1:] {
+				if t.array && hasPrefix(key, t.path) {
+					array = true
+				}
+			}
+			cur = &tomlTable{path: key, array: array, start: lineStart, insert: p.pos}
+			doc.tables = append(doc.tables, cur)
+		default:
+			key, err := p.key()
+			if err != nil {
+				return nil, err
+			}
+			p.blank()
+			if p.peek() != '=' {
+				return nil, p.errorf("expected = after key")
+			}
+			p.pos++
+			p.blank()
+			valStart := p.pos
+			if err := p.value(); err != nil {
+				return nil, err
+			}
+			valEnd := p.pos
+			p.skipLine()
+			doc.entries = append(doc.entries, tomlEntry{
+				table: cur, key: key,
+				lineStart: lineStart, lineEnd: p.pos,
+				valStart: valStart, valEnd: valEnd,
+			})
+			cur.insert, cur.indent = p.pos, lineIndent(data, lineStart)
+			cur.keys++
+		}
+	}
+	return doc, nil
+}
+
+type tomlScanner struct {
+	data []byte
+	pos  int
+}
+
+func (p *tomlScanner) errorf(format string, args ...any) error {
+	line := bytes.Count(p.data[:p.pos], []byte("\n")) + 1
+	return fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+func (p *tomlScanner) peek() byte {
+	if p.pos < len(p.data) {
+		return p.data[p.pos]
+	}
+	return 0
+}
+
+func (p *tomlScanner) has(s string) bool {
+	return bytes.HasPrefix(p.data[p.pos:], []byte(s))
+}
+
+func (p *tomlScanner) blank() {
+	for c := p.peek(); c == ' ' || c == '\t'; c = p.peek() {
+		p.pos++
+	}
+}
+
+func (p *tomlScanner) skipLine() {
+	if i := bytes.IndexByte(p.data[p.pos:], '\n'); i >= 0 {
+		p.pos += i + 1
+	} else {
+		p.pos = len(p.data)
+	}
+}
+
+// key reads a possibly dotted, possibly quoted key.
+func (p *tomlScanner) key() ([]string, error) {
+	var key []string
+	for {
+		p.blank()
+		start := p.pos
+		switch p.peek() {
+		case '"', '\'':
+			if err := p.value(); err != nil {
+				return nil, err
+			}
+			raw := string(p.data[start:p.pos])
+			seg := raw[1 : len(raw)-1]
+			if raw[0] == '"' {
+				if s, err := strconv.Unquote(raw); err == nil {
+					seg = s
+				}
+			}
+			key = append(key, seg)
+		default:
+			for bareKeyChar(p.peek()) {
+				p.pos++
+			}
+			if p.pos == start {
+				return nil, p.errorf("expected a key")
+			}
+			key = append(key, string(p.data[start:p.pos]))
+		}
+		p.blank()
+		if p.peek() != '.' {
+			return key, nil
+		}
+		p.pos++
+	}
+}
+
+// value moves past one value: a string of any kind, an array or inline
+// table, or anything else up to the end of the line or a comment.
+func (p *tomlScanner) value() error {
+	start := p.pos
+	switch {
+	case p.has(`"""`), p.has(`'''`):
+		quote := string(p.data[p.pos : p.pos+3])
+		for p.pos += 3; p.pos < len(p.data); p.pos++ {
+			if quote == `"""` && p.peek() == '\\' {
+				p.pos++
+				continue
+			}
+			if p.has(quote) {
+				p.pos += 3
+				// A closing delimiter may be preceded by up to two quotes.
+				for i := 0; i < 2 && p.peek() == quote[0]; i++ {
+					p.pos++
+				}
+				return nil
+			}
+		}
+	case p.peek() == '"', p.peek() == '\'':
+		quote := p.peek()
+		for p.pos++; p.pos < len(p.data) && p.peek() != '\n'; p.pos++ {
+			if quote == '"' && p.peek() == '\\' {
+				p.pos++
+				continue
+			}
+			if p.peek() == quote {
+				p.pos++
+				return nil
+			}
+		}
+	case p.peek() == '[', p.peek() == '{':
+		depth := 0
+		for p.pos < len(p.data) {
+			switch p.peek() {
+			case '[', '{':
+				depth++
+			case ']', '}':
+				depth--
+				if depth == 0 {
+					p.pos++
+					return nil
+				}
+			case '"', '\'':
+				if err := p.value(); err != nil {
+					return err
+				}
+				continue
+			case '#':
+				p.skipLine()
+				continue
+			}
+			p.pos++
+		}
+	default:
+		for c := p.peek(); c != 0 && c != '\n' && c != '#'; c = p.peek() {
+			p.pos++
+		}
+		for p.pos > start && strings.IndexByte(" \t\r", p.data[p.pos-1]) >= 0 {
+			p.pos--
+		}
+		if p.pos == start {
+			return p.errorf("expected a value")
+		}
+		return nil
+	}
+	p.pos = start
+	return p.errorf("unterminated value")
+}
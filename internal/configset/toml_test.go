@@ -0,0 +1,95 @@
+package configset
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSetTOML(t *testing.T) {
+	cases := []struct {
+		name  string
+		in    string
+		pairs []any
+		want  string
+	}{
+		{
+			name:  "replace keeps comments",
+			in:    "[server]\nhost = \"localhost\" # dev\nport = 3000\n",
+			pairs: []any{"server.host", "0.0.0.0", "server.port", int64(4000)},
+			want:  "[server]\nhost = \"0.0.0.0\" # dev\nport = 4000\n",
+		},
+		{
+			name:  "add after the last key of the table",
+			in:    "[server]\n  host = \"a\"\n\n# database\n[db]\nurl = \"x\"",
+			pairs: []any{"server.port", int64(1), "db.pool", int64(5)},
+			want:  "[server]\n  host = \"a\"\n  port = 1\n\n# database\n[db]\nurl = \"x\"\npool = 5\n",
+		},
+		{
+			name:  "new root key goes above the tables",
+			in:    "# config\n\n# the server\n[server]\nhost = \"a\"\n",
+			pairs: []any{"title", "app"},
+			want:  "# config\n\ntitle = \"app\"\n\n# the server\n[server]\nhost = \"a\"\n",
+		},
+		{
+			name:  "new table",
+			in:    "title = \"app\"\n",
+			pairs: []any{"tool.black.line-length", int64(100)},
+			want:  "title = \"app\"\n\n[tool.black]\nline-length = 100\n",
+		},
+		{
+			name:  "dotted keys",
+			in:    "name = \"app\"\nlog.level = \"info\"\n\n[server]\n",
+			pairs: []any{"log.level", "debug", "log.format", "json"},
+			want:  "name = \"app\"\nlog.level = \"debug\"\nlog.format = \"json\"\n\n[server]\n",
+		},
+		{
+			name:  "multi-line values",
+			in:    "deps = [\n  \"a\", # first\n  \"b\",\n]\ntext = '''\nx ] '''\nafter = 1\n",
+			pairs: []any{"deps", []any{"c"}, "text", "y\n", "after", int64(2)},
+			want:  "deps = [\"c\"]\ntext = \"y\\n\"\nafter = 2\n",
+		},
+		{
+			name:  "quoted keys",
+			in:    "[hosts]\n\"example.com\" = \"1.2.3.4\"\n",
+			pairs: []any{`hosts.example\.com`, "5.6.7.8", "hosts.a b", "x"},
+			want:  "[hosts]\n\"example.com\" = \"5.6.7.8\"\n\"a b\" = \"x\"\n",
+		},
+		{
+			name:  "crlf",
+			in:    "[a]\r\nb = 1\r\n",
+			pairs: []any{"a.c", int64(2), "d.e", int64(3)},
+			want:  "[a]\r\nb = 1\r\nc = 2\r\n\r\n[d]\r\ne = 3\r\n",
+		},
+		{
+			name:  "inline table value",
+			in:    "",
+			pairs: []any{"a", map[string]any{"y": float64(1.5), "x": float64(2)}},
+			want:  "a = { x = 2, y = 1.5 }\n",
+		},
+	}
+	for _, c := range cases {
+		if got := set(t, TOML, c.in, c.pairs...); got != c.want {
+			t.Errorf("%s:\ngot:\n%s\nwant:\n%s", c.name, got, c.want)
+		}
+	}
+}
+
+func TestSetTOML_Errors(t *testing.T) {
+	cases := []struct{ in, path, want string }{
+		{"a = 1\n", "a.b", "a is not an object"},
+		{"a = { b = 1 }\n", "a.c", "a is an inline table"},
+		{"[a.b]\nc = 1\n", "a", "a is a table"},
+		{"[[bin]]\nname = \"x\"\n", "bin.name", "array of tables"},
+		{"a = \"unterminated\n", "b", "line 1: unterminated value"},
+		{"a 1\n", "b", "line 1: expected ="},
+	}
+	for _, c := range cases {
+		err := setErr(TOML, c.in, c.path, "x")
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("setting %s in %q: %v; want %q", c.path, c.in, err, c.want)
+		}
+	}
+	if err := setErr(TOML, "", "a", []any{nil}); err == nil {
+		t.Error("writing null succeeded")
+	}
+}
@@ -0,0 +1,133 @@
+package configset
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// setYAML edits the document's node tree, which keeps comments and key
+// order; the file is then re-encoded with its own indentation width.
+func setYAML(data []byte, a Assignment) ([]byte, error) {
+	var doc yaml.Node
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for n := 0; ; n++ {
+		var next yaml.Node
+		err := dec.Decode(&next)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("file is not valid YAML: %w", err)
+		}
+		if n > 0 {
+			return nil, errors.New("file has several YAML documents; config-set edits files with one")
+		}
+		doc = next
+	}
+	if doc.Kind == 0 {
+		doc.Kind = yaml.DocumentNode
+	}
+	if len(doc.Content) == 0 {
+		doc.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+	}
+
+	node := doc.Content[0]
+	for i, key := range a.Path {
+		if node.Kind == yaml.AliasNode {
+			// Editing the anchored value would change every alias of it.
+			return nil, fmt.Errorf("%s is an alias of &%s; set the value where the anchor is", where(a.Path[:i]), node.Value)
+		}
+		var slot **yaml.Node
+		switch node.Kind {
+		case yaml.SequenceNode:
+			n, err := strconv.Atoi(key)
+			if err != nil || n < 0 || n >= len(node.Content) {
+				return nil, fmt.Errorf("%s has no element %s", where(a.Path[:i]), key)
+			}
+			slot = &node.Content[n]
+		case yaml.MappingNode:
+			for j := 0; j+1 < len(node.Content); j += 2 {
+				if node.Content[j].Value == key {
+					slot = &node.Content[j+1]
+				}
+			}
+			if slot == nil {
+				k, err := yamlNode(key)
+				if err != nil {
+					return nil, err
+				}
+				v, err := yamlNode(nest(a.Path[i+1:], a.Value))
+				if err != nil {
+					return nil, err
+				}
+				node.Content = append(node.Content, k, v)
+				return encodeYAML(&doc, data)
+			}
+		default:
+			return nil, fmt.Errorf("%s %w", where(a.Path[:i]), errNotTable)
+		}
+
+		if i < len(a.Path)-1 {
+			node = *slot
+			continue
+		}
+		v, err := yamlNode(a.Value)
+		if err != nil {
+			return nil, err
+		}
+		old := *slot
+		v.HeadComment, v.LineComment, v.FootComment = old.HeadComment, old.LineComment, old.FootComment
+		if old.Kind == yaml.ScalarNode && v.Kind == yaml.ScalarNode && v.Tag == "!!str" &&
+			old.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0 {
+			v.Style = old.Style
+		}
+		*slot = v
+	}
+	return encodeYAML(&doc, data)
+}
+
+// yamlNode encodes v as a node, keeping the key order of an orderedMap.
+func yamlNode(v any) (*yaml.Node, error) {
+	if m, ok := v.(orderedMap); ok {
+		n := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, kv := range m {
+			for _, part := range kv {
+				c, err := yamlNode(part)
+				if err != nil {
+					return nil, err
+				}
+				n.Content = append(n.Content, c)
+			}
+		}
+		return n, nil
+	}
+	n := &yaml.Node{}
+	if err := n.Encode(v); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// encodeYAML encodes doc with the indentation width of the original text.
+func encodeYAML(doc *yaml.Node, original []byte) ([]byte, error) {
+	width := len(strings.ReplaceAll(detectIndent(string(original)), "\t", "  "))
+	if width < 2 {
+		width = 2
+	}
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(width)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
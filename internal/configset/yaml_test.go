@@ -0,0 +1,64 @@
+package configset
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSetYAML(t *testing.T) {
+	in := `# services for local development
+services:
+    web:
+        image: "app:latest" # pinned
+        ports:
+            - "3000:3000"
+`
+	got := set(t, YAML, in,
+		"services.web.image", "app:v2",
+		"services.web.ports.0", "4000:3000",
+		"services.web.replicas", int64(2),
+		"services.db.environment.POSTGRES_DB", "feature_x",
+	)
+	want := `# services for local development
+services:
+    web:
+        image: "app:v2" # pinned
+        ports:
+            - "4000:3000"
+        replicas: 2
+    db:
+        environment:
+            POSTGRES_DB: feature_x
+`
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	if got := set(t, YAML, "", "a.b", true); got != "a:\n  b: true\n" {
+		t.Errorf("empty file: got %q", got)
+	}
+	if got := set(t, YAML, "a: old\n", "a", "true"); got != "a: \"true\"\n" {
+		t.Errorf("string that looks like a bool: got %q", got)
+	}
+}
+
+func TestSetYAML_Errors(t *testing.T) {
+	if err := setErr(YAML, "a: 1\n", "a.b", "x"); !errors.Is(err, errNotTable) {
+		t.Errorf("setting below a number: %v", err)
+	}
+	if err := setErr(YAML, "a: [\n", "a", "x"); err == nil {
+		t.Error("editing invalid YAML succeeded")
+	}
+	multi := "a: 1\n---\nkind: Service\nname: web\n"
+	if err := setErr(YAML, multi, "a", "2"); err == nil || !strings.Contains(err.Error(), "several YAML documents") {
+		t.Errorf("editing a multi-document file: %v", err)
+	}
+	anchored := "base: &base\n  port: 1\ndev: *base\n"
+	if err := setErr(YAML, anchored, "dev.port", "2"); err == nil || !strings.Contains(err.Error(), "alias of &base") {
+		t.Errorf("editing through an alias: %v", err)
+	}
+	if got := set(t, YAML, anchored, "dev", "x"); got != "base: &base\n  port: 1\ndev: x\n" {
+		t.Errorf("replacing an alias: got %q", got)
+	}
+}
@@ -0,0 +1,295 @@
+// Package dotenv reads and edits .env files. A File keeps the source text of
+// every line, so writing it back reproduces the input byte for byte except
+// for the entries that were changed.
+//
+// The syntax is the common one: KEY=VALUE lines with an optional `export`
+// prefix and spaces around `=`; single-quoted values are literal,
+// double-quoted values may span lines and use \n, \t, \", \\ and \$ escapes,
+// and unquoted values end at a ` #` inline comment. Lines that are not
+// assignments, or whose value cannot be read this way (`A="x"y`, or a quote
+// that is never closed), are kept as they are and do not assign anything.
+package dotenv
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// reKey matches a valid variable name.
+var reKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// line is one logical line of a file: an assignment, which may span several
+// physical lines when its value is quoted, or anything else.
+type line struct {
+	text  string // source text without the line ending
+	eol   string // "\n", "\r\n", or "" for a last line without one
+	key   string // "" when the line is not an assignment
+	value string // decoded value
+	head  string // text before the value, e.g. `export KEY = `
+	quote byte   // 0, '\'' or '"'
+	tail  string // text after the value: spaces and an inline comment
+}
+
+// File is a parsed .env file.
+type File struct {
+	lines []*line
+	eol   string // line ending for appended lines
+}
+
+// Parse parses the contents of a .env file.
+func Parse(data []byte) *File {
+	src := string(data)
+	f := &File{eol: "\n"}
+	if strings.Contains(src, "\r\n") {
+		f.eol = "\r\n"
+	}
+	for src != "" {
+		l, rest := parseLine(src)
+		f.lines = append(f.lines, l)
+		src = rest
+	}
+	return f
+}
+
+// parseLine parses the logical line at the start of src and returns it with
+// the remaining input. A quoted value that cannot be read leaves its first
+// physical line as a line that is not an assignment.
+func parseLine(src string) (*line, string) {
+	l := &line{}
+	text, rest := cutLine(src, &l.eol)
+	l.text = text
+	verbatim := &line{text: text, eol: l.eol}
+	verbatimRest := rest
+
+	s := strings.TrimLeft(text, " \t")
+	if s == "" || s[0] == '#' {
+		return l, rest
+	}
+	if after, ok := strings.CutPrefix(s, "export"); ok && after != "" && (after[0] == ' ' || after[0] == '\t') {
+		s = strings.TrimLeft(after, " \t")
+	}
+	end := strings.IndexAny(s, "= \t")
+	if end <= 0 {
+		return l, rest
+	}
+	key := s[:end]
+	s = strings.TrimLeft(s[end:], " \t")
+	if !reKey.MatchString(key) || !strings.HasPrefix(s, "=") {
+		return l, rest
+	}
+	afterEq := s[1:]
+	s = strings.TrimLeft(afterEq, " \t")
+	l.key = key
+	l.head = text[:len(text)-len(s)]
+
+	if s != "" && s[0] == '#' && len(s) < len(afterEq) {
+		// `KEY= # comment` is an empty value.
+		l.head, l.tail = text[:len(text)-len(afterEq)], afterEq
+		return l, rest
+	}
+	if s == "" || (s[0] != '\'' && s[0] != '"') {
+		// Unquoted: the value ends at an inline comment or the line end.
+		value := s
+		if i := strings.Index(s, " #"); i >= 0 {
+			value = s[:i]
+		} else if i := strings.Index(s, "\t#"); i >= 0 {
+			value = s[:i]
+		}
+		value = strings.TrimRight(value, " \t\r")
+		l.value, l.tail = value, s[len(value):]
+		return l, rest
+	}
+
+	// Quoted: the value may run onto the following lines.
+	l.quote = s[0]
+	body := s[1:]
+	for {
+		value, after, ok := closeQuote(body, l.quote)
+		if ok {
+			tail := strings.TrimLeft(after, " \t\r")
+			if tail != "" && tail[0] != '#' {
+				return verbatim, verbatimRest
+			}
+			l.value, l.tail, l.text = value, after, text
+			return l, rest
+		}
+		if rest == "" {
+			return verbatim, verbatimRest
+		}
+		var next string
+		nl := l.eol
+		next, rest = cutLine(rest, &l.eol)
+		text += nl + next
+		body += nl + next
+	}
+}
+
+// cutLine splits the first physical line off src and stores its ending.
+func cutLine(src string, eol *string) (string, string) {
+	i := strings.IndexByte(src, '\n')
+	if i < 0 {
+		*eol = ""
+		return src, ""
+	}
+	if i > 0 && src[i-1] == '\r' {
+		*eol = "\r\n"
+		return src[:i-1], src[i+1:]
+	}
+	*eol = "\n"
+	return src[:i], src[i+1:]
+}
+
+// closeQuote finds the closing quote in s, which follows an opening quote,
+// and returns the decoded value and the text after the quote.
+func closeQuote(s string, quote byte) (value, after string, ok bool) {
+	if quote == '\'' {
+		i := strings.IndexByte(s, '\'')
+		if i < 0 {
+			return "", "", false
+		}
+		return s[:i], s[i+1:], true
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"':
+			return b.String(), s[i+1:], true
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case '"', '\\', '$', '`':
+				b.WriteByte(s[i])
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", "", false
+}
+
+// Get returns the value of key. When a key is assigned more than once the
+// last assignment wins, as it does when the file is sourced.
+func (f *File) Get(key string) (string, bool) {
+	for i := len(f.lines) - 1; i >= 0; i-- {
+		if f.lines[i].key == key {
+			return f.lines[i].value, true
+		}
+	}
+	return "", false
+}
+
+// Set assigns value to key. Every assignment of an existing key is rewritten
+// in place, keeping its `export` prefix, spacing, inline comment and, where
+// the value allows, its quoting; a new key is appended at the end.
+func (f *File) Set(key, value string) error {
+	if !reKey.MatchString(key) {
+		return fmt.Errorf("invalid key %q", key)
+	}
+	found := false
+	for _, l := range f.lines {
+		if l.key != key {
+			continue
+		}
+		found = true
+		if l.value == value {
+			continue
+		}
+		l.quote = quoteFor(value, l.quote)
+		l.value = value
+		l.text = l.head + encode(value, l.quote) + l.tail
+	}
+	if found {
+		return nil
+	}
+	if n := len(f.lines); n > 0 && f.lines[n-1].eol == "" {
+		f.lines[n-1].eol = f.eol
+	}
+	l := &line{key: key, value: value, head: key + "=", eol: f.eol, quote: quoteFor(value, 0)}
+	l.text = l.head + encode(value, l.quote)
+	f.lines = append(f.lines, l)
+	return nil
+}
+
+// Unset removes every assignment of key and reports whether there was one.
+func (f *File) Unset(key string) bool {
+	kept := f.lines[:0]
+	for _, l := range f.lines {
+		if l.key != key {
+			kept = append(kept, l)
+		}
+	}
+	removed := len(kept) < len(f.lines)
+	f.lines = kept
+	return removed
+}
+
+// Keys returns the keys assigned in the file, each once, in the order they
+// first appear.
+func (f *File) Keys() []string {
+	var keys []string
+	seen := make(map[string]bool)
+	for _, l := range f.lines {
+		if l.key != "" && !seen[l.key] {
+			seen[l.key] = true
+			keys = append(keys, l.key)
+		}
+	}
+	return keys
+}
+
+// Bytes returns the file's contents.
+func (f *File) Bytes() []byte {
+	var b strings.Builder
+	for _, l := range f.lines {
+		b.WriteString(l.text)
+		b.WriteString(l.eol)
+	}
+	return []byte(b.String())
+}
+
+// Quote renders value the way Set would write a new key's value: as is, or
+// double-quoted when it needs quoting.
+func Quote(value string) string {
+	return encode(value, quoteFor(value, 0))
+}
+
+// quoteFor returns the quoting to write value with, keeping prev, the
+// quoting it had, when that can represent it.
+func quoteFor(value string, prev byte) byte {
+	switch prev {
+	case '"':
+		return '"'
+	case '\'':
+		if !strings.ContainsAny(value, "'\n\r") {
+			return '\''
+		}
+		return '"'
+	}
+	if value == "" || !strings.ContainsAny(value, " \t\n\r#\"'`\\") {
+		return 0
+	}
+	return '"'
+}
+
+// encode renders value with the given quoting.
+func encode(value string, quote byte) string {
+	switch quote {
+	case '\'':
+		return "'" + value + "'"
+	case '"':
+		r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`", "\n", `\n`, "\r", `\r`)
+		return `"` + r.Replace(value) + `"`
+	}
+	return value
+}
@@ -0,0 +1,154 @@
+package dotenv
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// goldenCases pairs each testdata/<name>.env with the assignments applied to
+// it. testdata/<name>.golden holds the parsed values and the file after the
+// assignments; regenerate with `go test ./internal/dotenv -update`.
+var goldenCases = []struct {
+	name string
+	set  []string
+}{
+	{"basic", []string{"DEBUG=false", "DB_NAME=myapp_feature"}},
+	{"export", []string{"DB_HOST=db.internal", "DB_PORT=6543", "DB_USER=app"}},
+	{"quotes", []string{"SINGLE=still single", "DOUBLE=new \"value\"", "SWITCH=it's", "EMPTY_SINGLE=x"}},
+	{"comments", []string{"A=2", "B=y # still not a comment", "C=3", "D=needs quoting", "F=#hash"}},
+	{"multiline", []string{"CERT=-----BEGIN CERT-----\nNEW\n-----END CERT-----"}},
+	{"spacing", []string{"KEY=new", "SPACES_AROUND=narrow"}},
+	{"crlf", []string{"A=2", "NEW=appended"}},
+	{"noeol", []string{"B=3", "C=4"}},
+	{"duplicates", []string{"A=5"}},
+	{"empty", []string{"K=V", "SPACE=a b", "BACKSLASH=C:\\tmp", "DOLLAR=$HOME", "BLANK="}},
+	{"junk", []string{"GOOD=still"}},
+	{"unreadable", []string{"GOOD=new", "FOO=fixed"}},
+}
+
+func TestGolden(t *testing.T) {
+	for _, c := range goldenCases {
+		t.Run(c.name, func(t *testing.T) {
+			src, err := os.ReadFile(filepath.Join("testdata", c.name+".env"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			f := Parse(src)
+			if got := f.Bytes(); string(got) != string(src) {
+				t.Fatalf("round trip changed the file:\n got %q\nwant %q", got, src)
+			}
+
+			var b strings.Builder
+			b.WriteString("# values\n")
+			for _, l := range f.lines {
+				if l.key != "" {
+					fmt.Fprintf(&b, "%s=%q\n", l.key, l.value)
+				}
+			}
+			fmt.Fprintf(&b, "# after %q\n", c.set)
+			for _, kv := range c.set {
+				key, value, _ := strings.Cut(kv, "=")
+				if err := f.Set(key, value); err != nil {
+					t.Fatal(err)
+				}
+			}
+			out := f.Bytes()
+			b.Write(out)
+			got := b.String()
+
+			// What was written must read back as what was set.
+			again := Parse(out)
+			for _, kv := range c.set {
+				key, value, _ := strings.Cut(kv, "=")
+				if v, _ := again.Get(key); v != value {
+					t.Errorf("%s reads back as %q, want %q", key, v, value)
+				}
+			}
+
+			golden := filepath.Join("testdata", c.name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("got:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestGet_LastAssignmentWins(t *testing.T) {
+	f := Parse([]byte("A=1\nA=2\n"))
+	if v, ok := f.Get("A"); !ok || v != "2" {
+		t.Errorf("Get(A) = %q, %v", v, ok)
+	}
+	if _, ok := f.Get("B"); ok {
+		t.Error("Get(B) found a missing key")
+	}
+}
+
+func TestParse_KeepsUnreadableValues(t *testing.T) {
+	cases := map[string][]string{
+		"A=1\nB=\"open\nC=3\n": {"A", "C"},
+		"A='x' y\n":            nil,
+		"A=\"a\nb\"\nC='x'z\n": {"A"},
+	}
+	for src, keys := range cases {
+		f := Parse([]byte(src))
+		if got := string(f.Bytes()); got != src {
+			t.Errorf("Parse(%q) round trip = %q", src, got)
+		}
+		if got := strings.Join(f.Keys(), ","); got != strings.Join(keys, ",") {
+			t.Errorf("Parse(%q) keys = %s, want %s", src, got, strings.Join(keys, ","))
+		}
+	}
+}
+
+func TestSet_InvalidKey(t *testing.T) {
+	f := Parse(nil)
+	for _, key := range []string{"", "1A", "A B", "A=B"} {
+		if err := f.Set(key, "x"); err == nil {
+			t.Errorf("Set(%q) succeeded", key)
+		}
+	}
+}
+
+func TestUnsetAndKeys(t *testing.T) {
+	f := Parse([]byte("# db\nA=1\nexport B=2 # two\nA=3\nC=\"x\ny\"\n"))
+	if got := strings.Join(f.Keys(), ","); got != "A,B,C" {
+		t.Errorf("Keys = %s", got)
+	}
+	if !f.Unset("A") || f.Unset("MISSING") {
+		t.Error("Unset reported the wrong keys as present")
+	}
+	if got := string(f.Bytes()); got != "# db\nexport B=2 # two\nC=\"x\ny\"\n" {
+		t.Errorf("after Unset: %q", got)
+	}
+}
+
+func TestQuote(t *testing.T) {
+	cases := map[string]string{
+		"plain":     "plain",
+		"":          "",
+		"two words": `"two words"`,
+		"a\nb":      `"a\nb"`,
+		`say "hi"`:  `"say \"hi\""`,
+	}
+	for in, want := range cases {
+		if got := Quote(in); got != want {
+			t.Errorf("Quote(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
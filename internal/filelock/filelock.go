@@ -0,0 +1,32 @@
+// Package filelock provides advisory, cross-process locks on sidecar lock
+// files and atomic writes, for files that several wtw processes (or agents)
+// may update at the same time.
+package filelock
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Lock blocks until it holds an exclusive lock on path, creating the file
+// (and its directory) if needed. Call the returned function to release it.
+func Lock(path string) (unlock func() error, err error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return func() error {
+		err := unlockFile(f)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		return err
+	}, nil
+}
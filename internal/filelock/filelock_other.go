@@ -0,0 +1,12 @@
+//go:build !unix
+
+package filelock
+
+import "os"
+
+// Advisory locks are only implemented on Unix, the platforms wtw ships for.
+func lockFile(_ *os.File) error   { return nil }
+func unlockFile(_ *os.File) error { return nil }
+
+func copyOwner(_ *os.File, _ os.FileInfo) {}
+func syncDir(_ string)                    {}
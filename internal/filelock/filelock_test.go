@@ -0,0 +1,68 @@
+package filelock
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestLock_SerializesWriters(t *testing.T) {
+	dir := t.TempDir()
+	counter := filepath.Join(dir, "counter")
+	if err := os.WriteFile(counter, []byte("0"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	const workers, rounds = 8, 25
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := 0; r < rounds; r++ {
+				unlock, err := Lock(counter + ".lock")
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				data, _ := os.ReadFile(counter)
+				n, _ := strconv.Atoi(string(data))
+				_ = os.WriteFile(counter, []byte(strconv.Itoa(n+1)), 0o644)
+				if err := unlock(); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	data, _ := os.ReadFile(counter)
+	if got := string(data); got != strconv.Itoa(workers*rounds) {
+		t.Errorf("counter = %s, want %d", got, workers*rounds)
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state")
+
+	if err := WriteFile(path, []byte("one"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFile(path, []byte("two"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	info, _ := os.Stat(path)
+	if string(data) != "two" || info.Mode().Perm() != 0o600 {
+		t.Errorf("got %q with mode %v, want \"two\" with mode 0600", data, info.Mode().Perm())
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("%d files in %s, want only the written one", len(entries), dir)
+	}
+}
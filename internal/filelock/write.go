@@ -0,0 +1,58 @@
+package filelock
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// WriteFile replaces path with data atomically: it writes a temporary file
+// in the same directory, syncs it and renames it over path, so readers see
+// the old contents or the new, never a mix. A symlink is followed and its
+// target replaced. The new file keeps the mode of the one it replaces, or
+// gets perm, and keeps its owner where the process may set it.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		path = real
+	}
+	info, err := os.Stat(path)
+	switch {
+	case err == nil:
+		perm = info.Mode().Perm()
+	case !errors.Is(err, os.ErrNotExist):
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer func() { _ = os.Remove(tmpPath) }()
+
+	err = writeSynced(tmp, data, perm, info)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(path))
+	return nil
+}
+
+func writeSynced(f *os.File, data []byte, perm os.FileMode, old os.FileInfo) error {
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	if err := f.Chmod(perm); err != nil {
+		return err
+	}
+	if old != nil {
+		copyOwner(f, old)
+	}
+	return f.Sync()
+}
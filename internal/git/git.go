@@ -15,6 +15,18 @@ func Output(args ...string) (string, error) {
 	return strings.TrimSpace(string(out)), err
 }
 
+// OutputIn runs a git command in dir and returns trimmed stdout.
+func OutputIn(dir string, args ...string) (string, error) {
+	return Output(append([]string{"-C", dir}, args...)...)
+}
+
+// CombinedOutputIn runs a git command in dir and returns trimmed
+// stdout+stderr. Used where git's own diagnostics belong in a report.
+func CombinedOutputIn(dir string, args ...string) (string, error) {
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+	return strings.TrimSpace(string(out)), err
+}
+
 // Run runs a git command in dir, streaming stdout/stderr to the terminal.
 func Run(dir string, args ...string) error {
 	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
@@ -28,6 +40,42 @@ func RepoRoot() (string, error) {
 	return Output("rev-parse", "--show-toplevel")
 }
 
+// MainRepoRoot returns the root of the main worktree, even when called from
+// inside a linked worktree.
+func MainRepoRoot() (string, error) {
+	common, err := Output("rev-parse", "--git-common-dir")
+	if err != nil {
+		return "", err
+	}
+	common, err = filepath.Abs(common)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Dir(common), nil
+}
+
+// MainRepoRootOf is MainRepoRoot for the worktree at dir.
+func MainRepoRootOf(dir string) (string, error) {
+	common, err := CommonDir(dir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Dir(common), nil
+}
+
+// CommonDir returns the absolute git directory shared by every worktree of
+// the repository containing dir: the main repo's .git.
+func CommonDir(dir string) (string, error) {
+	common, err := OutputIn(dir, "rev-parse", "--git-common-dir")
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(common) {
+		common = filepath.Join(dir, common)
+	}
+	return filepath.Clean(common), nil
+}
+
 // RequireWorktree asserts the cwd is inside a linked worktree, not the main repo.
 // Returns (worktreeRoot, mainRepoRoot, error).
 //
@@ -141,15 +189,130 @@ func BranchExists(repoRoot, branch string) bool {
 	return strings.TrimSpace(string(out)) != ""
 }
 
-// AddWorktree creates a new worktree at path on branch (creating branch if needed).
-func AddWorktree(repoRoot, path, branch string) error {
-	if BranchExists(repoRoot, branch) {
-		return Run(repoRoot, "worktree", "add", path, branch)
+// RemoteBranch returns the remote-tracking branch (e.g. "origin/feature-x")
+// that a new local branch called branch should track: origin's if it has
+// one, else the only remote's. It is "" when there is none or it is ambiguous.
+func RemoteBranch(repoRoot, branch string) string {
+	out, err := OutputIn(repoRoot, "for-each-ref", "--format=%(refname:short)", "refs/remotes/*/"+branch)
+	if err != nil || out == "" {
+		return ""
 	}
-	return Run(repoRoot, "worktree", "add", "-b", branch, path)
+	refs := strings.Split(out, "\n")
+	for _, r := range refs {
+		if r == "origin/"+branch {
+			return r
+		}
+	}
+	if len(refs) == 1 {
+		return refs[0]
+	}
+	return ""
 }
 
 // RemoveWorktree removes a worktree (force).
 func RemoveWorktree(mainRepoRoot, path string) error {
 	return Run(mainRepoRoot, "worktree", "remove", "--force", path)
 }
+
+// DeleteBranch force-deletes a local branch.
+func DeleteBranch(repoRoot, branch string) error {
+	_, err := CombinedOutputIn(repoRoot, "branch", "-D", branch)
+	return err
+}
+
+// IsDirty returns true if the worktree at dir has uncommitted changes,
+// including untracked files.
+func IsDirty(dir string) bool {
+	out, err := OutputIn(dir, "status", "--porcelain")
+	return err != nil || out != ""
+}
+
+// Upstream returns the upstream ref (e.g. "origin/main") of branch, or "".
+func Upstream(dir, branch string) string {
+	out, err := OutputIn(dir, "rev-parse", "--abbrev-ref", "--symbolic-full-name", branch+"@{upstream}")
+	if err != nil {
+		return ""
+	}
+	return out
+}
+
+// CurrentBranch returns the branch checked out in dir, or "" when detached.
+func CurrentBranch(dir string) string {
+	out, _ := OutputIn(dir, "branch", "--show-current")
+	return out
+}
+
+// GitDir returns the absolute git directory of the worktree at dir: the
+// main repo's .git, or .git/worktrees/<name> for a linked worktree.
+func GitDir(dir string) (string, error) {
+	return OutputIn(dir, "rev-parse", "--absolute-git-dir")
+}
+
+// SetBranchBase records base as the branch that branch was created from.
+// Stored in git config as branch.<branch>.wtwBase so it travels with the repo
+// and is cleaned up by `git branch -D`.
+func SetBranchBase(repoRoot, branch, base string) error {
+	_, err := OutputIn(repoRoot, "config", "branch."+branch+".wtwBase", base)
+	return err
+}
+
+// BranchBase returns the recorded base of branch, or "".
+func BranchBase(repoRoot, branch string) string {
+	out, _ := OutputIn(repoRoot, "config", "--get", "branch."+branch+".wtwBase")
+	return out
+}
+
+// UntrackedMatching returns the untracked paths under repoRoot that match the
+// gitignore-style patterns, relative to repoRoot. Directories whose whole
+// content matches are returned once, with a trailing "/".
+func UntrackedMatching(repoRoot string, patterns []string) ([]string, error) {
+	f, err := os.CreateTemp("", "wtw-patterns-*")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+	if _, err := f.WriteString(strings.Join(patterns, "\n") + "\n"); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+
+	out, err := exec.Command("git", "-C", repoRoot, "ls-files", "-z",
+		"--others", "--ignored", "--directory", "--exclude-from="+f.Name()).Output()
+	if err != nil {
+		return nil, err
+	}
+	return ParseUntrackedMatching(string(out)), nil
+}
+
+// ParseUntrackedMatching parses NUL-separated `git ls-files --directory`
+// output. git sometimes lists a directory and also the matching files inside
+// it; in that case the individual files are kept and the directory dropped.
+// Exported so tests can call it directly without running git.
+func ParseUntrackedMatching(out string) []string {
+	var entries []string
+	for _, e := range strings.Split(out, "\x00") {
+		if e != "" {
+			entries = append(entries, e)
+		}
+	}
+	var paths []string
+	for _, e := range entries {
+		if strings.HasSuffix(e, "/") && hasEntryUnder(entries, e) {
+			continue
+		}
+		paths = append(paths, e)
+	}
+	return paths
+}
+
+func hasEntryUnder(entries []string, dir string) bool {
+	for _, e := range entries {
+		if e != dir && strings.HasPrefix(e, dir) {
+			return true
+		}
+	}
+	return false
+}
@@ -68,3 +68,17 @@ detached
 		}
 	}
 }
+
+func TestParseUntrackedMatching(t *testing.T) {
+	out := ".env\x00node_modules/\x00sub/\x00sub/.env\x00"
+	got := ParseUntrackedMatching(out)
+	want := []string{".env", "node_modules/", "sub/.env"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("entry %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,208 @@
+// Package interp expands ${NAME} references in strings, with filters such as
+// ${BRANCH_NAME|snake|max:63} to turn values into valid identifiers.
+package interp
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"wtw/internal/slug"
+)
+
+// Mode says what Interpolate does with references.
+type Mode int
+
+const (
+	Literal Mode = iota // leave the string as it is
+	Expand              // expand references; undefined variables are empty
+	Strict              // expand references; undefined variables are an error
+	Keep                // expand references; undefined variables are left as written
+)
+
+// Modes lists the mode names ParseMode accepts.
+const Modes = "literal, expand or strict"
+
+// ParseMode returns the mode called name.
+func ParseMode(name string) (Mode, error) {
+	switch name {
+	case "literal":
+		return Literal, nil
+	case "expand":
+		return Expand, nil
+	case "strict":
+		return Strict, nil
+	}
+	return 0, fmt.Errorf("unknown mode %q: want %s", name, Modes)
+}
+
+func (m Mode) String() string {
+	return [...]string{"literal", "expand", "strict", "keep"}[m]
+}
+
+// Lookup returns the value of a variable and whether it is defined.
+type Lookup func(name string) (string, bool)
+
+// Chain returns a Lookup that tries each of lookups in turn.
+func Chain(lookups ...Lookup) Lookup {
+	return func(name string) (string, bool) {
+		for _, l := range lookups {
+			if v, ok := l(name); ok {
+				return v, true
+			}
+		}
+		return "", false
+	}
+}
+
+// Vars returns a Lookup over KEY=VALUE entries, such as the variables passed
+// to setup scripts.
+func Vars(env []string) Lookup {
+	return func(name string) (string, bool) {
+		for _, kv := range env {
+			if k, v, _ := strings.Cut(kv, "="); k == name {
+				return v, true
+			}
+		}
+		return "", false
+	}
+}
+
+// ErrUndefined is returned in Strict mode for a reference to a variable that
+// is not defined and has no default.
+var ErrUndefined = errors.New("undefined variable")
+
+// Interpolate replaces each ${NAME} or ${NAME|filter|filter:arg} in s.
+// $${ stands for a literal ${. The filters are:
+//
+//	lower, upper   change case
+//	snake, kebab   lower case with runs of other characters replaced by _ or -
+//	dns, db        a DNS label or database name, as made by `wtw slug`
+//	max:N          keep the first N characters
+//	default:TEXT   use TEXT when the variable is undefined or empty
+//
+// A ${...} that is not such a reference, like the shell's ${VAR:-default}
+// or one using an unknown filter, is left as written in every mode.
+func Interpolate(s string, mode Mode, lookup Lookup) (string, error) {
+	if mode == Literal {
+		return s, nil
+	}
+	var b strings.Builder
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+		if i > 0 && s[i-1] == '$' {
+			b.WriteString(s[:i])
+			b.WriteString("{")
+			s = s[i+2:]
+			continue
+		}
+		b.WriteString(s[:i])
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			b.WriteString(s[i:])
+			return b.String(), nil
+		}
+		value, ok, err := expandRef(s[i+2:i+end], mode, lookup)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			value = s[i : i+end+1]
+		}
+		b.WriteString(value)
+		s = s[i+end+1:]
+	}
+}
+
+// expandRef expands the inside of one ${...}. ok is false when it is to be
+// left as written.
+func expandRef(ref string, mode Mode, lookup Lookup) (value string, ok bool, err error) {
+	parts := strings.Split(ref, "|")
+	name := strings.TrimSpace(parts[0])
+	if !validName(name) {
+		return "", false, nil
+	}
+	hasDefault := false
+	for _, f := range parts[1:] {
+		fname, _, _ := strings.Cut(strings.TrimSpace(f), ":")
+		if _, known := filters[fname]; !known {
+			return "", false, nil
+		}
+		if fname == "default" {
+			hasDefault = true
+		}
+	}
+	value, defined := lookup(name)
+	if !defined && !hasDefault {
+		switch mode {
+		case Strict:
+			return "", false, fmt.Errorf("%w %s", ErrUndefined, name)
+		case Keep:
+			return "", false, nil
+		}
+	}
+	for _, f := range parts[1:] {
+		fname, arg, _ := strings.Cut(strings.TrimSpace(f), ":")
+		if value, err = filters[fname](value, arg); err != nil {
+			return "", false, fmt.Errorf("%s in ${%s}: %w", fname, ref, err)
+		}
+	}
+	return value, true, nil
+}
+
+func validName(name string) bool {
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		return false
+	}
+	for _, c := range name {
+		if c != '_' && (c > unicode.MaxASCII || !unicode.IsLetter(c) && !unicode.IsDigit(c)) {
+			return false
+		}
+	}
+	return true
+}
+
+// filters maps filter names to functions of the value and the argument
+// after the colon.
+var filters = map[string]func(value, arg string) (string, error){
+	"lower": func(v, _ string) (string, error) { return strings.ToLower(v), nil },
+	"upper": func(v, _ string) (string, error) { return strings.ToUpper(v), nil },
+	"snake": slugFilter(slug.Snake),
+	"kebab": slugFilter(slug.Kebab),
+	"dns":   slugFilter(slug.DNS),
+	"db":    slugFilter(slug.DB),
+	"max": func(v, arg string) (string, error) {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 0 {
+			return "", errors.New("want a length, as in max:63")
+		}
+		if utf8.RuneCountInString(v) <= n {
+			return v, nil
+		}
+		return string([]rune(v)[:n]), nil
+	},
+	"default": func(v, arg string) (string, error) {
+		if v == "" {
+			return arg, nil
+		}
+		return v, nil
+	},
+}
+
+// slugFilter returns a filter that makes a slug of the value in style, as
+// `wtw slug` does. An empty value stays empty.
+func slugFilter(style slug.Style) func(v, _ string) (string, error) {
+	return func(v, _ string) (string, error) {
+		if v == "" {
+			return "", nil
+		}
+		return slug.Make(v, style, 0)
+	}
+}
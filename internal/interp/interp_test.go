@@ -0,0 +1,87 @@
+package interp
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	lookup := Vars([]string{
+		"BRANCH_NAME=feature/Login-Page",
+		"WTW_PORT=41000",
+		"EMPTY=",
+		"LONG=abcdefghij",
+	})
+	cases := []struct{ in, want string }{
+		{"plain", "plain"},
+		{"http://localhost:${WTW_PORT}/", "http://localhost:41000/"},
+		{"${BRANCH_NAME|snake}", "feature_login_page"},
+		{"${BRANCH_NAME|kebab|upper}", "FEATURE-LOGIN-PAGE"},
+		{"${BRANCH_NAME|db}", "feature_login_page"},
+		{"${WTW_PORT|dns}", "41000"},
+		{"${MISSING|db}", ""},
+		{"${ BRANCH_NAME | lower }", "feature/login-page"},
+		{"${LONG|max:4}", "abcd"},
+		{"${LONG|max:40}", "abcdefghij"},
+		{"${MISSING}", ""},
+		{"${MISSING|default:app}", "app"},
+		{"${EMPTY|default:app}", "app"},
+		{"$${WTW_PORT} ${WTW_PORT}", "${WTW_PORT} 41000"},
+		{"$HOME and $", "$HOME and $"},
+	}
+	for _, c := range cases {
+		got, err := Interpolate(c.in, Expand, lookup)
+		if err != nil || got != c.want {
+			t.Errorf("Interpolate(%q) = %q, %v; want %q", c.in, got, err, c.want)
+		}
+	}
+}
+
+func TestInterpolate_Modes(t *testing.T) {
+	lookup := Vars([]string{"A=1"})
+	if got, _ := Interpolate("${A}${B}", Literal, lookup); got != "${A}${B}" {
+		t.Errorf("literal: %q", got)
+	}
+	if _, err := Interpolate("${A}${B}", Strict, lookup); !errors.Is(err, ErrUndefined) {
+		t.Errorf("strict: err = %v, want ErrUndefined", err)
+	}
+	if got, err := Interpolate("${B|default:2}", Strict, lookup); err != nil || got != "2" {
+		t.Errorf("strict with default: %q, %v", got, err)
+	}
+}
+
+func TestInterpolate_Keep(t *testing.T) {
+	lookup := Vars([]string{"A=1"})
+	if got, err := Interpolate("${A}${B}${B|upper}${B|default:x}", Keep, lookup); err != nil || got != "1${B}${B|upper}x" {
+		t.Errorf("keep: %q, %v", got, err)
+	}
+}
+
+func TestInterpolate_LeavesOtherBracesAlone(t *testing.T) {
+	for _, mode := range []Mode{Expand, Strict, Keep} {
+		for _, in := range []string{"${DB_PORT:-5432}", "${x:+y}", "${A|nope}", "${1A}", "${}", "${A"} {
+			if got, err := Interpolate(in, mode, Vars([]string{"A=1", "DB_PORT=1"})); err != nil || got != in {
+				t.Errorf("Interpolate(%q, %s) = %q, %v; want it unchanged", in, mode, got, err)
+			}
+		}
+	}
+}
+
+func TestInterpolate_Errors(t *testing.T) {
+	if _, err := Interpolate("${A|max:x}", Expand, Vars(nil)); err == nil {
+		t.Error("a bad filter argument succeeded")
+	}
+}
+
+func TestChain(t *testing.T) {
+	l := Chain(Vars([]string{"A=file"}), Vars([]string{"A=env", "B=env"}))
+	if v, _ := l("A"); v != "file" {
+		t.Errorf("A = %q, want the first lookup's value", v)
+	}
+	if v, _ := l("B"); v != "env" {
+		t.Errorf("B = %q", v)
+	}
+	if _, ok := l("C"); ok {
+		t.Error("C found")
+	}
+}
@@ -0,0 +1,291 @@
+// Package ports hands out a stable block of TCP ports to each worktree, so
+// dev servers in different worktrees don't collide. Allocations live in a
+// registry file in the user config dir, shared by every repo on the machine
+// and guarded by an advisory lock.
+package ports
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"wtw/internal/filelock"
+)
+
+// Defaults used when git config does not say otherwise.
+const (
+	DefaultMin       = 3100
+	DefaultMax       = 3999
+	DefaultBlockSize = 10
+)
+
+// Config describes the port range blocks are carved from.
+type Config struct {
+	Min       int      `json:"min"` // inclusive range
+	Max       int      `json:"max"`
+	BlockSize int      `json:"block_size"`      // ports per worktree
+	Names     []string `json:"names,omitempty"` // optional names for the first ports of a block, e.g. web, db
+}
+
+// Allocation is the block of ports assigned to one worktree.
+type Allocation struct {
+	Worktree string   `json:"worktree"`
+	Repo     string   `json:"repo"`
+	Branch   string   `json:"branch"`
+	Index    int      `json:"index"` // block number within the range
+	Ports    []int    `json:"ports"`
+	Names    []string `json:"names,omitempty"`
+}
+
+type registry struct {
+	Allocations []Allocation `json:"allocations"`
+}
+
+// portFree is swapped out in tests.
+var portFree = func(port int) bool {
+	l, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+	if err != nil {
+		return false
+	}
+	_ = l.Close()
+	return true
+}
+
+// RegistryPath returns the registry location in the user config dir.
+func RegistryPath() (string, error) {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "wtw", "ports.json"), nil
+}
+
+// ParseRange parses "3100-3999".
+func ParseRange(s string) (lo, hi int, err error) {
+	a, b, ok := strings.Cut(s, "-")
+	if ok {
+		lo, err = strconv.Atoi(strings.TrimSpace(a))
+		if err == nil {
+			hi, err = strconv.Atoi(strings.TrimSpace(b))
+		}
+	}
+	if !ok || err != nil || lo < 1 || hi > 65535 || lo > hi {
+		return 0, 0, fmt.Errorf("invalid port range %q: expected e.g. 3100-3999", s)
+	}
+	return lo, hi, nil
+}
+
+// Allocate returns the worktree's existing allocation, or reserves the lowest
+// free block in the range. A block is only handed out when none of its ports
+// is taken by another worktree or currently in use on this machine.
+// Allocations of worktrees that no longer exist on disk are dropped first.
+func Allocate(cfg Config, repo, worktree, branch string) (Allocation, error) {
+	if cfg.BlockSize < 1 || cfg.Min+cfg.BlockSize-1 > cfg.Max {
+		return Allocation{}, fmt.Errorf("port range %d-%d cannot hold a block of %d", cfg.Min, cfg.Max, cfg.BlockSize)
+	}
+	if len(cfg.Names) > cfg.BlockSize {
+		return Allocation{}, fmt.Errorf("%d port names but only %d ports per worktree", len(cfg.Names), cfg.BlockSize)
+	}
+
+	var out Allocation
+	err := update(func(reg *registry) error {
+		reg.prune()
+		for _, a := range reg.Allocations {
+			if a.Worktree == worktree {
+				out = a
+				return nil
+			}
+		}
+
+		taken := map[int]bool{}
+		for _, a := range reg.Allocations {
+			for _, p := range a.Ports {
+				taken[p] = true
+			}
+		}
+
+		blocks := (cfg.Max - cfg.Min + 1) / cfg.BlockSize
+		for i := 0; i < blocks; i++ {
+			ports := make([]int, cfg.BlockSize)
+			ok := true
+			for j := range ports {
+				ports[j] = cfg.Min + i*cfg.BlockSize + j
+				if taken[ports[j]] || !portFree(ports[j]) {
+					ok = false
+					break
+				}
+			}
+			if !ok {
+				continue
+			}
+			out = Allocation{
+				Worktree: worktree,
+				Repo:     repo,
+				Branch:   branch,
+				Index:    i,
+				Ports:    ports,
+				Names:    cfg.Names,
+			}
+			reg.Allocations = append(reg.Allocations, out)
+			return nil
+		}
+		return fmt.Errorf("no free block of %d ports left in %d-%d", cfg.BlockSize, cfg.Min, cfg.Max)
+	})
+	return out, err
+}
+
+// Lookup returns the allocation for worktree, if any.
+func Lookup(worktree string) (Allocation, bool) {
+	path, err := RegistryPath()
+	if err != nil {
+		return Allocation{}, false
+	}
+	reg, err := load(path)
+	if err != nil {
+		return Allocation{}, false
+	}
+	for _, a := range reg.Allocations {
+		if a.Worktree == worktree {
+			return a, true
+		}
+	}
+	return Allocation{}, false
+}
+
+// All returns every allocation in the registry, sorted by first port.
+func All() ([]Allocation, error) {
+	path, err := RegistryPath()
+	if err != nil {
+		return nil, err
+	}
+	reg, err := load(path)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(reg.Allocations, func(i, j int) bool {
+		return reg.Allocations[i].Ports[0] < reg.Allocations[j].Ports[0]
+	})
+	return reg.Allocations, nil
+}
+
+// Free releases the ports of worktree. Freeing an unknown worktree is a no-op.
+func Free(worktree string) error {
+	return update(func(reg *registry) error {
+		kept := reg.Allocations[:0]
+		for _, a := range reg.Allocations {
+			if a.Worktree != worktree {
+				kept = append(kept, a)
+			}
+		}
+		reg.Allocations = kept
+		return nil
+	})
+}
+
+// Port returns the port called name in a, where name is one of a.Names or
+// an index into the block ("0", "1", ...).
+func (a Allocation) Port(name string) (int, error) {
+	for i, n := range a.Names {
+		if n == name {
+			return a.Ports[i], nil
+		}
+	}
+	if i, err := strconv.Atoi(name); err == nil && i >= 0 && i < len(a.Ports) {
+		return a.Ports[i], nil
+	}
+	return 0, fmt.Errorf("no port named %q", name)
+}
+
+// Env returns the variables exposed to setup scripts: WTW_PORT (the first
+// port), WTW_PORT_1 ... WTW_PORT_<n-1>, WTW_PORT_<NAME> for named ports and
+// WORKTREE_INDEX.
+func (a Allocation) Env() []string {
+	env := []string{
+		"WORKTREE_INDEX=" + strconv.Itoa(a.Index),
+		"WTW_PORT=" + strconv.Itoa(a.Ports[0]),
+	}
+	for i := 1; i < len(a.Ports); i++ {
+		env = append(env, fmt.Sprintf("WTW_PORT_%d=%d", i, a.Ports[i]))
+	}
+	for i, n := range a.Names {
+		env = append(env, fmt.Sprintf("WTW_PORT_%s=%d", envName(n), a.Ports[i]))
+	}
+	return env
+}
+
+// envName upper-cases a port name and replaces characters that are not valid
+// in variable names, e.g. "vite-hmr" → "VITE_HMR".
+func envName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// prune drops allocations whose worktree directory is gone.
+func (reg *registry) prune() {
+	kept := reg.Allocations[:0]
+	for _, a := range reg.Allocations {
+		if _, err := os.Stat(a.Worktree); err == nil {
+			kept = append(kept, a)
+		}
+	}
+	reg.Allocations = kept
+}
+
+// update loads the registry under its lock, applies fn and saves the result.
+func update(fn func(*registry) error) error {
+	path, err := RegistryPath()
+	if err != nil {
+		return err
+	}
+	unlock, err := filelock.Lock(path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = unlock() }()
+
+	reg, err := load(path)
+	if err != nil {
+		return err
+	}
+	if err := fn(reg); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(reg, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// load reads the registry; a missing file is an empty registry.
+func load(path string) (*registry, error) {
+	reg := &registry{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return reg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, reg); err != nil {
+		return nil, fmt.Errorf("corrupt port registry %s: %w", path, err)
+	}
+	return reg, nil
+}
@@ -0,0 +1,134 @@
+package ports
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// isolate points the registry at a temp config dir and makes every port
+// look free unless listed in busy.
+func isolate(t *testing.T, busy ...int) {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	orig := portFree
+	t.Cleanup(func() { portFree = orig })
+	portFree = func(p int) bool {
+		for _, b := range busy {
+			if b == p {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func mkWorktree(t *testing.T) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "app-feat")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestAllocate_StableAndDistinct(t *testing.T) {
+	isolate(t)
+	cfg := Config{Min: 4000, Max: 4099, BlockSize: 10}
+	a, b := mkWorktree(t), mkWorktree(t)
+
+	first, err := Allocate(cfg, "/repo", a, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Allocate(cfg, "/repo", b, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Ports[0] != 4000 || second.Ports[0] != 4010 || second.Index != 1 {
+		t.Errorf("blocks = %v / %v, want 4000 and 4010", first.Ports, second.Ports)
+	}
+
+	again, err := Allocate(cfg, "/repo", a, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again, first) {
+		t.Errorf("re-allocation changed: %+v, want %+v", again, first)
+	}
+	if got, ok := Lookup(b); !ok || got.Index != 1 {
+		t.Errorf("Lookup(b) = %+v, %v", got, ok)
+	}
+
+	if err := Free(a); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := Lookup(a); ok {
+		t.Error("allocation still present after Free")
+	}
+}
+
+func TestAllocate_SkipsBusyPorts(t *testing.T) {
+	isolate(t, 4003)
+	a, err := Allocate(Config{Min: 4000, Max: 4099, BlockSize: 5}, "/repo", mkWorktree(t), "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Ports[0] != 4005 {
+		t.Errorf("first port = %d, want 4005 (block with busy 4003 skipped)", a.Ports[0])
+	}
+}
+
+func TestAllocate_PrunesMissingWorktrees(t *testing.T) {
+	isolate(t)
+	cfg := Config{Min: 4000, Max: 4009, BlockSize: 10}
+	gone := mkWorktree(t)
+	if _, err := Allocate(cfg, "/repo", gone, "gone"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Allocate(cfg, "/repo", mkWorktree(t), "full"); err == nil {
+		t.Fatal("expected range to be exhausted")
+	}
+	if err := os.RemoveAll(gone); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Allocate(cfg, "/repo", mkWorktree(t), "new"); err != nil {
+		t.Errorf("block of removed worktree was not reclaimed: %v", err)
+	}
+}
+
+func TestAllocation_EnvAndPort(t *testing.T) {
+	a := Allocation{Index: 2, Ports: []int{3120, 3121, 3122}, Names: []string{"web", "vite-hmr"}}
+	want := []string{
+		"WORKTREE_INDEX=2",
+		"WTW_PORT=3120",
+		"WTW_PORT_1=3121",
+		"WTW_PORT_2=3122",
+		"WTW_PORT_WEB=3120",
+		"WTW_PORT_VITE_HMR=3121",
+	}
+	if got := a.Env(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Env = %v, want %v", got, want)
+	}
+	if p, err := a.Port("vite-hmr"); err != nil || p != 3121 {
+		t.Errorf("Port(vite-hmr) = %d, %v", p, err)
+	}
+	if p, err := a.Port("2"); err != nil || p != 3122 {
+		t.Errorf("Port(2) = %d, %v", p, err)
+	}
+	if _, err := a.Port("db"); err == nil {
+		t.Error("expected error for unknown port name")
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	if lo, hi, err := ParseRange("3100 - 3999"); err != nil || lo != 3100 || hi != 3999 {
+		t.Errorf("ParseRange = %d, %d, %v", lo, hi, err)
+	}
+	for _, bad := range []string{"3100", "x-y", "4000-3000", "0-10", "1-70000"} {
+		if _, _, err := ParseRange(bad); err == nil {
+			t.Errorf("ParseRange(%q): expected error", bad)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package prompt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// errBudget is returned when the dirty check runs out of time.
+var errBudget = errors.New("time budget exceeded")
+
+// indexEntry is the subset of a git index entry needed for a stat check.
+type indexEntry struct {
+	path      string
+	mtimeSec  uint32
+	mtimeNsec uint32
+	mode      uint32
+	size      uint32
+	skip      bool // assume-unchanged or skip-worktree
+}
+
+const (
+	flagAssumeValid   = 0x8000
+	flagExtended      = 0x4000
+	flagSkipWorktree  = 0x4000 // in the extended flags word
+	modeGitlink       = 0o160000
+	modeSymlink       = 0o120000
+	entryFixedNoHash  = 40 // ctime..size
+	checkBudgetPeriod = 64
+)
+
+// isDirty reports whether any tracked file in wt differs from the index by
+// size or mtime — the same stat shortcut git uses before hashing content.
+// Untracked files are not considered. It gives up with errBudget once
+// deadline passes.
+func isDirty(wt Worktree, deadline time.Time) (bool, error) {
+	data, err := os.ReadFile(filepath.Join(wt.GitDir, "index"))
+	if err != nil {
+		return false, err
+	}
+	entries, err := parseIndex(data, hashSize(wt.GitDir))
+	if err != nil {
+		return false, err
+	}
+
+	for i, e := range entries {
+		if i%checkBudgetPeriod == 0 && time.Now().After(deadline) {
+			return false, errBudget
+		}
+		if e.skip || e.mode == modeGitlink {
+			continue
+		}
+		info, err := os.Lstat(filepath.Join(wt.Root, filepath.FromSlash(e.path)))
+		if err != nil {
+			return true, nil
+		}
+		if (e.mode == modeSymlink) != (info.Mode()&os.ModeSymlink != 0) {
+			return true, nil
+		}
+		if uint32(info.Size()) != e.size {
+			return true, nil
+		}
+		mt := info.ModTime()
+		if uint32(mt.Unix()) != e.mtimeSec {
+			return true, nil
+		}
+		// Builds without nanosecond support store 0.
+		if e.mtimeNsec != 0 && uint32(mt.Nanosecond()) != e.mtimeNsec {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// hashSize returns the object hash length for the repository owning gitDir:
+// 32 for sha256 repositories, 20 otherwise.
+func hashSize(gitDir string) int {
+	common := gitDir
+	if rel, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		common = strings.TrimSpace(string(rel))
+		if !filepath.IsAbs(common) {
+			common = filepath.Join(gitDir, common)
+		}
+	}
+	cfg, err := os.ReadFile(filepath.Join(common, "config"))
+	if err == nil && bytes.Contains(bytes.ToLower(cfg), []byte("objectformat = sha256")) {
+		return 32
+	}
+	return 20
+}
+
+// parseIndex decodes the entries of a version 2, 3 or 4 git index file.
+// Extensions and the trailing checksum are ignored.
+func parseIndex(data []byte, hashLen int) ([]indexEntry, error) {
+	if len(data) < 12 || string(data[:4]) != "DIRC" {
+		return nil, errors.New("not a git index")
+	}
+	version := binary.BigEndian.Uint32(data[4:8])
+	if version < 2 || version > 4 {
+		return nil, fmt.Errorf("unsupported index version %d", version)
+	}
+	count := binary.BigEndian.Uint32(data[8:12])
+
+	entries := make([]indexEntry, 0, count)
+	pos := 12
+	prev := ""
+	fixed := entryFixedNoHash + hashLen + 2
+	for i := uint32(0); i < count; i++ {
+		start := pos
+		if pos+fixed > len(data) {
+			return nil, errors.New("truncated index")
+		}
+		e := indexEntry{
+			mtimeSec:  binary.BigEndian.Uint32(data[pos+8:]),
+			mtimeNsec: binary.BigEndian.Uint32(data[pos+12:]),
+			mode:      binary.BigEndian.Uint32(data[pos+24:]),
+			size:      binary.BigEndian.Uint32(data[pos+36:]),
+		}
+		flags := binary.BigEndian.Uint16(data[pos+entryFixedNoHash+hashLen:])
+		e.skip = flags&flagAssumeValid != 0
+		pos += fixed
+		if version >= 3 && flags&flagExtended != 0 {
+			if pos+2 > len(data) {
+				return nil, errors.New("truncated index")
+			}
+			e.skip = e.skip || binary.BigEndian.Uint16(data[pos:])&flagSkipWorktree != 0
+			pos += 2
+		}
+
+		if version == 4 {
+			strip, n := decodeOffset(data[pos:])
+			if n == 0 || strip > len(prev) {
+				return nil, errors.New("corrupt index path prefix")
+			}
+			pos += n
+			end := bytes.IndexByte(data[pos:], 0)
+			if end < 0 {
+				return nil, errors.New("truncated index")
+			}
+			e.path = prev[:len(prev)-strip] + string(data[pos:pos+end])
+			pos += end + 1
+		} else {
+			end := bytes.IndexByte(data[pos:], 0)
+			if end < 0 {
+				return nil, errors.New("truncated index")
+			}
+			e.path = string(data[pos : pos+end])
+			// Entries are NUL-padded to a multiple of 8 bytes.
+			pos = start + (pos-start+end+8)&^7
+		}
+		prev = e.path
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// decodeOffset decodes git's variable-length offset encoding used for index
+// v4 path prefixes. It returns the value and the number of bytes consumed, or
+// n=0 if the input is truncated.
+func decodeOffset(b []byte) (val, n int) {
+	if len(b) == 0 {
+		return 0, 0
+	}
+	c := b[0]
+	n = 1
+	val = int(c & 0x7f)
+	for c&0x80 != 0 {
+		if n >= len(b) {
+			return 0, 0
+		}
+		c = b[n]
+		n++
+		val = ((val + 1) << 7) | int(c&0x7f)
+	}
+	return val, n
+}
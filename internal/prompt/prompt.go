@@ -0,0 +1,115 @@
+// Package prompt renders a short shell-prompt segment for the current
+// worktree. It reads .git files, HEAD and the index directly instead of
+// running git, so it is cheap enough to call from PS1 on every prompt.
+package prompt
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultFormat renders as e.g. "wt:feature-login*".
+const DefaultFormat = "wt:{branch}{dirty}"
+
+// Worktree describes the linked worktree containing a directory.
+type Worktree struct {
+	Root   string // worktree root (the directory holding the .git file)
+	GitDir string // .git/worktrees/<name> in the main repo
+	Name   string // <name> under .git/worktrees
+	Branch string // checked-out branch, or short commit when detached
+}
+
+// Find locates the linked worktree containing dir by walking up to the
+// nearest .git entry. It returns ok=false in the main repo, in submodules and
+// outside any repository.
+func Find(dir string) (wt Worktree, ok bool) {
+	for {
+		info, err := os.Lstat(filepath.Join(dir, ".git"))
+		if err == nil {
+			if info.IsDir() {
+				return Worktree{}, false
+			}
+			return readWorktree(dir)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return Worktree{}, false
+		}
+		dir = parent
+	}
+}
+
+// readWorktree reads the "gitdir: ..." pointer in root/.git and the HEAD it
+// points at.
+func readWorktree(root string) (Worktree, bool) {
+	data, err := os.ReadFile(filepath.Join(root, ".git"))
+	if err != nil {
+		return Worktree{}, false
+	}
+	gitDir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir: ")
+	if !ok {
+		return Worktree{}, false
+	}
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(root, gitDir)
+	}
+	// Submodules also use a .git file, but point into .git/modules/.
+	if filepath.Base(filepath.Dir(gitDir)) != "worktrees" {
+		return Worktree{}, false
+	}
+
+	head, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return Worktree{}, false
+	}
+	return Worktree{
+		Root:   root,
+		GitDir: gitDir,
+		Name:   filepath.Base(gitDir),
+		Branch: parseHead(string(head)),
+	}, true
+}
+
+// parseHead returns the branch named by a HEAD file, or the abbreviated
+// commit when HEAD is detached.
+func parseHead(head string) string {
+	head = strings.TrimSpace(head)
+	if ref, ok := strings.CutPrefix(head, "ref: "); ok {
+		return strings.TrimPrefix(ref, "refs/heads/")
+	}
+	if len(head) > 7 {
+		return head[:7]
+	}
+	return head
+}
+
+// Segment renders format for the worktree containing dir, or "" outside a
+// linked worktree. Placeholders: {branch}, {name} (the worktree's directory
+// name), {path} and {dirty} ("*" when modified, "?" when the check did not
+// finish within budget).
+func Segment(dir, format string, budget time.Duration) string {
+	wt, ok := Find(dir)
+	if !ok {
+		return ""
+	}
+
+	dirty := ""
+	if strings.Contains(format, "{dirty}") {
+		switch d, err := isDirty(wt, time.Now().Add(budget)); {
+		case errors.Is(err, errBudget):
+			dirty = "?"
+		case d:
+			dirty = "*"
+		}
+	}
+
+	return strings.NewReplacer(
+		"{branch}", wt.Branch,
+		"{name}", filepath.Base(wt.Root),
+		"{path}", wt.Root,
+		"{dirty}", dirty,
+	).Replace(format)
+}
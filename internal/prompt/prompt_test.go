@@ -0,0 +1,158 @@
+package prompt
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// setupWorktree creates a repo with a few committed files and a linked
+// worktree on branch "feature/login". Returns (repoRoot, worktreeRoot).
+func setupWorktree(t *testing.T) (string, string) {
+	t.Helper()
+	base := t.TempDir()
+	repo := filepath.Join(base, "app")
+	wt := filepath.Join(base, "app-feature-login")
+
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("HOME", base)
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	git := func(dir string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Join(repo, "src", "deep"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"README.md":            "hello\n",
+		"src/main.go":          "package main\n",
+		"src/deep/helper.go":   "package deep\n",
+		"src/deep/helper2.go":  "package deep\n",
+		"src/deep/other_a.txt": "a\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(repo, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git(repo, "init", "-q")
+	git(repo, "add", ".")
+	git(repo, "commit", "-q", "-m", "initial")
+	git(repo, "worktree", "add", "-q", "-b", "feature/login", wt)
+	return repo, wt
+}
+
+func TestSegment(t *testing.T) {
+	repo, wt := setupWorktree(t)
+
+	if got := Segment(wt, DefaultFormat, time.Second); got != "wt:feature/login" {
+		t.Errorf("clean segment = %q", got)
+	}
+	if got := Segment(filepath.Join(wt, "src", "deep"), "{name}", time.Second); got != "app-feature-login" {
+		t.Errorf("{name} from subdir = %q", got)
+	}
+	if got := Segment(repo, DefaultFormat, time.Second); got != "" {
+		t.Errorf("main repo segment = %q, want empty", got)
+	}
+	if got := Segment(t.TempDir(), DefaultFormat, time.Second); got != "" {
+		t.Errorf("non-repo segment = %q, want empty", got)
+	}
+
+	if err := os.WriteFile(filepath.Join(wt, "src", "main.go"), []byte("package main // changed\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := Segment(wt, DefaultFormat, time.Second); got != "wt:feature/login*" {
+		t.Errorf("dirty segment = %q", got)
+	}
+}
+
+func TestSegment_DeletedFileIsDirty(t *testing.T) {
+	_, wt := setupWorktree(t)
+	if err := os.Remove(filepath.Join(wt, "README.md")); err != nil {
+		t.Fatal(err)
+	}
+	if got := Segment(wt, "{dirty}", time.Second); got != "*" {
+		t.Errorf("dirty = %q, want *", got)
+	}
+}
+
+func TestSegment_BudgetExceeded(t *testing.T) {
+	_, wt := setupWorktree(t)
+	if got := Segment(wt, "{dirty}", -time.Second); got != "?" {
+		t.Errorf("dirty with no budget = %q, want ?", got)
+	}
+}
+
+func TestParseIndex_Versions(t *testing.T) {
+	_, wt := setupWorktree(t)
+	want := []string{"README.md", "src/deep/helper.go", "src/deep/helper2.go", "src/deep/other_a.txt", "src/main.go"}
+
+	for _, v := range []string{"2", "3", "4"} {
+		cmd := exec.Command("git", "-C", wt, "update-index", "--index-version", v)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("update-index: %v\n%s", err, out)
+		}
+		found, _ := Find(wt)
+		data, err := os.ReadFile(filepath.Join(found.GitDir, "index"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries, err := parseIndex(data, 20)
+		if err != nil {
+			t.Fatalf("v%s: %v", v, err)
+		}
+		if len(entries) != len(want) {
+			t.Fatalf("v%s: %d entries, want %d", v, len(entries), len(want))
+		}
+		for i, e := range entries {
+			if e.path != want[i] {
+				t.Errorf("v%s: entry %d = %q, want %q", v, i, e.path, want[i])
+			}
+		}
+		if d, err := isDirty(found, time.Now().Add(time.Second)); d || err != nil {
+			t.Errorf("v%s: isDirty = %v, %v; want clean", v, d, err)
+		}
+	}
+}
+
+func TestParseHead(t *testing.T) {
+	cases := []struct{ in, want string }{
+		{"ref: refs/heads/main\n", "main"},
+		{"ref: refs/heads/feature/x\n", "feature/x"},
+		{"0123456789abcdef0123456789abcdef01234567\n", "0123456"},
+	}
+	for _, tc := range cases {
+		if got := parseHead(tc.in); got != tc.want {
+			t.Errorf("parseHead(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestDecodeOffset(t *testing.T) {
+	cases := []struct {
+		in     []byte
+		val, n int
+	}{
+		{[]byte{0x00}, 0, 1},
+		{[]byte{0x7f}, 127, 1},
+		{[]byte{0x80, 0x00}, 128, 2},
+		{[]byte{0x80}, 0, 0},
+	}
+	for _, tc := range cases {
+		val, n := decodeOffset(tc.in)
+		if val != tc.val || n != tc.n {
+			t.Errorf("decodeOffset(%x) = %d, %d; want %d, %d", tc.in, val, n, tc.val, tc.n)
+		}
+	}
+}
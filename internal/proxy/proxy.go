@@ -0,0 +1,216 @@
+// Package proxy implements `wtw proxy`: a local HTTP reverse proxy that
+// routes <worktree-name>.<suffix> to the dev server port allocated to that
+// worktree in the port registry.
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"wtw/internal/ports"
+)
+
+// Route maps a hostname to a local port.
+type Route struct {
+	Host     string // e.g. myapp-feature-x.localhost
+	Branch   string
+	Worktree string
+	Port     int
+}
+
+// Config holds inputs for New.
+type Config struct {
+	Suffix   string // hostname suffix, e.g. "localhost"
+	PortName string // which port of a worktree's block to route to; "" means the first
+	// Load returns the current allocations; defaults to ports.All.
+	Load func() ([]ports.Allocation, error)
+}
+
+// Server is an http.Handler that proxies by Host header.
+type Server struct {
+	cfg    Config
+	mu     sync.RWMutex
+	routes map[string]Route // by lower-case host
+}
+
+// New returns a Server with its routes loaded.
+func New(cfg Config) (*Server, error) {
+	if cfg.Suffix == "" {
+		cfg.Suffix = "localhost"
+	}
+	cfg.Suffix = strings.ToLower(strings.Trim(cfg.Suffix, "."))
+	if cfg.Load == nil {
+		cfg.Load = ports.All
+	}
+	s := &Server{cfg: cfg}
+	if _, err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload rebuilds the route table from the port registry and reports
+// whether it changed.
+func (s *Server) Reload() (bool, error) {
+	allocs, err := s.cfg.Load()
+	if err != nil {
+		return false, err
+	}
+	routes := make(map[string]Route, len(allocs))
+	for _, a := range allocs {
+		port := a.Ports[0]
+		if s.cfg.PortName != "" {
+			p, err := a.Port(s.cfg.PortName)
+			if err != nil {
+				continue
+			}
+			port = p
+		}
+		host := strings.ToLower(filepath.Base(a.Worktree)) + "." + s.cfg.Suffix
+		routes[host] = Route{Host: host, Branch: a.Branch, Worktree: a.Worktree, Port: port}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changed := len(routes) != len(s.routes)
+	for h, r := range routes {
+		if s.routes[h] != r {
+			changed = true
+		}
+	}
+	s.routes = routes
+	return changed, nil
+}
+
+// Routes returns the current routes sorted by host.
+func (s *Server) Routes() []Route {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]Route, 0, len(s.routes))
+	for _, r := range s.routes {
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Host < out[j].Host })
+	return out
+}
+
+// Watch reloads the routes whenever the port registry file changes, until
+// ctx is done. onChange is called after each reload that altered the routes.
+func (s *Server) Watch(ctx context.Context, interval time.Duration, onChange func()) {
+	path, err := ports.RegistryPath()
+	if err != nil {
+		return
+	}
+	var last time.Time
+	if info, err := os.Stat(path); err == nil {
+		last = info.ModTime()
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		info, err := os.Stat(path)
+		if err != nil || info.ModTime().Equal(last) {
+			continue
+		}
+		last = info.ModTime()
+		if changed, err := s.Reload(); err == nil && changed && onChange != nil {
+			onChange()
+		}
+	}
+}
+
+// lookup finds the route for a Host header. Subdomains of a worktree host
+// (api.myapp-x.localhost) route to the same worktree.
+func (s *Server) lookup(hostHeader string) (Route, bool) {
+	host := strings.ToLower(hostHeader)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if r, ok := s.routes[host]; ok {
+		return r, true
+	}
+	for h, r := range s.routes {
+		if strings.HasSuffix(host, "."+h) {
+			return r, true
+		}
+	}
+	return Route{}, false
+}
+
+// ServeHTTP proxies requests for known worktree hosts, including WebSocket
+// upgrades, and serves the status page for everything else.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	route, ok := s.lookup(r.Host)
+	if !ok {
+		s.serveStatus(w, r)
+		return
+	}
+	target := &url.URL{Scheme: "http", Host: net.JoinHostPort("127.0.0.1", strconv.Itoa(route.Port))}
+	rp := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			pr.Out.Host = pr.In.Host
+			pr.SetXForwarded()
+		},
+		ErrorHandler: func(w http.ResponseWriter, _ *http.Request, err error) {
+			http.Error(w, fmt.Sprintf("wtw proxy: nothing is listening for %s on port %d (%v)", route.Host, route.Port, err), http.StatusBadGateway)
+		},
+	}
+	rp.ServeHTTP(w, r)
+}
+
+var statusPage = template.Must(template.New("status").Parse(`<!doctype html>
+<html><head><meta charset="utf-8"><title>wtw proxy</title>
+<style>body{font-family:sans-serif;margin:2em}td,th{padding:.3em 1em;text-align:left}</style>
+</head><body>
+<h1>wtw proxy</h1>
+{{if .Unknown}}<p>No worktree is routed at <code>{{.Unknown}}</code>.</p>{{end}}
+{{if .Routes}}<table>
+<tr><th>Host</th><th>Port</th><th>Branch</th><th>Worktree</th></tr>
+{{range .Routes}}<tr><td><a href="http://{{.Host}}{{$.PortSuffix}}/">{{.Host}}</a></td><td>{{.Port}}</td><td>{{.Branch}}</td><td>{{.Worktree}}</td></tr>
+{{end}}</table>{{else}}<p>No worktrees have ports allocated yet.</p>{{end}}
+</body></html>
+`))
+
+// serveStatus lists all routes. Unknown worktree hosts get a 404.
+func (s *Server) serveStatus(w http.ResponseWriter, r *http.Request) {
+	host, port, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host, port = r.Host, ""
+	}
+	data := struct {
+		Unknown    string
+		Routes     []Route
+		PortSuffix string
+	}{Routes: s.Routes()}
+	if port != "" {
+		data.PortSuffix = ":" + port
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	host = strings.ToLower(host)
+	if host != s.cfg.Suffix && host != "127.0.0.1" && host != "localhost" {
+		data.Unknown = host
+		w.WriteHeader(http.StatusNotFound)
+	}
+	_ = statusPage.Execute(w, data)
+}
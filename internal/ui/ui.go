@@ -51,3 +51,13 @@ func Ask(prompt string) string {
 	line, _ := reader.ReadString('\n')
 	return strings.TrimSpace(line)
 }
+
+// IsInteractive reports whether stdin is a terminal, i.e. whether prompts can
+// be answered.
+func IsInteractive() bool {
+	st, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return (st.Mode() & os.ModeCharDevice) != 0
+}
@@ -31,7 +31,7 @@ var (
 	downloadTimeout    = 30 * time.Second
 	now                = time.Now
 	executablePathFunc = os.Executable
-	isInteractiveFn    = isInteractive
+	isInteractiveFn    = ui.IsInteractive
 )
 
 type cacheState struct {
@@ -460,11 +460,3 @@ func saveState(path string, st cacheState) {
 	}
 	_ = os.WriteFile(path, data, 0o644)
 }
-
-func isInteractive() bool {
-	st, err := os.Stdin.Stat()
-	if err != nil {
-		return false
-	}
-	return (st.Mode() & os.ModeCharDevice) != 0
-}
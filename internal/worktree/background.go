@@ -0,0 +1,221 @@
+package worktree
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"wtw/internal/ui"
+)
+
+// BackgroundSetupConfig holds inputs for StartBackgroundSetup and
+// RunBackgroundSetup.
+type BackgroundSetupConfig struct {
+	SetupScript  string
+	WorktreePath string
+	BranchName   string
+	RepoRoot     string
+	OriginalDir  string
+	Steps        StepSelection
+	Timeout      time.Duration // 0 means no limit
+	Interpreter  string        // may be empty (uses the script's shebang)
+	Inputs       []string      // files the setup depends on; nil means DefaultSetupInputs
+	RunID        string        // RunBackgroundSetup only: the run to resume
+}
+
+// StartBackgroundSetup launches `wtw setup-worker` detached from the
+// terminal to run the setup script, and returns the log path immediately.
+// The script must already be trusted: nobody can answer a prompt from there.
+func StartBackgroundSetup(cfg BackgroundSetupConfig) (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("cannot find the wtw binary: %w", err)
+	}
+	run, err := beginSetupRun(cfg.WorktreePath, cfg.SetupScript, true)
+	if err != nil {
+		return "", err
+	}
+	if run.log == nil {
+		return "", fmt.Errorf("not a git worktree: %s", cfg.WorktreePath)
+	}
+	defer func() { _ = run.log.Close() }()
+
+	args := []string{"setup-worker",
+		"--run", run.status.ID,
+		"--setup", cfg.SetupScript,
+		"--worktree", cfg.WorktreePath,
+		"--branch", cfg.BranchName,
+		"--repo", cfg.RepoRoot,
+		"--original-dir", cfg.OriginalDir,
+	}
+	if cfg.Timeout > 0 {
+		args = append(args, "--timeout", cfg.Timeout.String())
+	}
+	if cfg.Interpreter != "" {
+		args = append(args, "--interpreter", cfg.Interpreter)
+	}
+	for _, in := range cfg.Inputs {
+		args = append(args, "--input", in)
+	}
+	for _, s := range cfg.Steps.Only {
+		args = append(args, "--only", s)
+	}
+	for _, s := range cfg.Steps.Skip {
+		args = append(args, "--skip", s)
+	}
+	cmd := exec.Command(exe, args...)
+	cmd.Dir = cfg.WorktreePath
+	cmd.Stdout = run.log
+	cmd.Stderr = run.log
+	cmd.SysProcAttr = detachedProcAttr()
+	if err := cmd.Start(); err != nil {
+		err = fmt.Errorf("failed to start background setup: %w", err)
+		run.fail(err)
+		return "", err
+	}
+	err = run.recordWorker(cmd.Process.Pid)
+	_ = cmd.Process.Release()
+	if err != nil {
+		return "", err
+	}
+	return run.status.Log, nil
+}
+
+// RunBackgroundSetup is the body of `wtw setup-worker`: it runs the setup
+// script of the run that StartBackgroundSetup began, with output going to
+// the run's log. The script's failure is recorded, not returned.
+func RunBackgroundSetup(cfg BackgroundSetupConfig) error {
+	run, err := resumeSetupRun(cfg.WorktreePath, cfg.RunID)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("wtw: running %s in %s (%s)\n", filepath.Base(cfg.SetupScript), cfg.WorktreePath, run.status.Started.Format(time.RFC3339))
+
+	if _, err := ensureTrusted(cfg.SetupScript, cfg.RepoRoot); err != nil {
+		run.fail(err)
+		fmt.Printf("wtw: setup failed: %v\n", err)
+		return nil
+	}
+	err = runSetupFile(run, setupSpec{
+		script:       cfg.SetupScript,
+		steps:        cfg.Steps,
+		timeout:      cfg.Timeout,
+		interpreter:  cfg.Interpreter,
+		inputs:       cfg.Inputs,
+		worktreePath: cfg.WorktreePath,
+		branchName:   cfg.BranchName,
+		repoRoot:     cfg.RepoRoot,
+		originalDir:  cfg.OriginalDir,
+	})
+	if err != nil {
+		fmt.Printf("wtw: setup failed: %v\n", err)
+	} else {
+		fmt.Println("wtw: setup succeeded")
+	}
+	return nil
+}
+
+// SetupStatusConfig holds inputs for Status and Wait.
+type SetupStatusConfig struct {
+	WorktreePath string
+	Lines        int           // log lines to show; 0 means 10
+	Timeout      time.Duration // Wait only; 0 means no limit
+}
+
+// Status prints the state of the worktree's latest setup run and the tail
+// of its log.
+func Status(cfg SetupStatusConfig) error {
+	st, ok, err := ReadSetupStatus(cfg.WorktreePath)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Println("No setup has been recorded in " + cfg.WorktreePath + ".")
+		return nil
+	}
+	printSetupStatus(cfg, st)
+	return nil
+}
+
+// waitInterval is shortened in tests.
+var waitInterval = 500 * time.Millisecond
+
+// Wait blocks until the worktree's latest setup run finishes, then prints
+// its status. It returns an error when the setup failed or cfg.Timeout passed.
+func Wait(cfg SetupStatusConfig) error {
+	var deadline time.Time
+	if cfg.Timeout > 0 {
+		deadline = time.Now().Add(cfg.Timeout)
+	}
+	for {
+		st, ok, err := ReadSetupStatus(cfg.WorktreePath)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("no setup has been recorded in " + cfg.WorktreePath)
+		}
+		if st.done() {
+			printSetupStatus(cfg, st)
+			if st.failed() {
+				return errors.New("setup " + st.State)
+			}
+			return nil
+		}
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return fmt.Errorf("setup still running after %s", cfg.Timeout)
+		}
+		time.Sleep(waitInterval)
+	}
+}
+
+func printSetupStatus(cfg SetupStatusConfig, st SetupStatus) {
+	switch st.State {
+	case SetupRunning:
+		fmt.Printf("Setup running for %s.\n", time.Since(st.Started).Round(time.Second))
+	case SetupSucceeded:
+		ui.Success(fmt.Sprintf("Setup succeeded in %s.", st.Finished.Sub(st.Started).Round(time.Second)))
+	case SetupFailed:
+		ui.Error("Setup failed: " + st.Error)
+	default:
+		ui.Error("Setup " + st.State + ".")
+	}
+	fmt.Println("Script: " + st.Script)
+
+	lines := cfg.Lines
+	if lines == 0 {
+		lines = 10
+	}
+	tail, err := tailFile(st.Log, lines)
+	if err != nil || len(tail) == 0 {
+		return
+	}
+	fmt.Println("Log: " + st.Log)
+	for _, l := range tail {
+		fmt.Println("  " + l)
+	}
+}
+
+// tailFile returns the last n lines of a file.
+func tailFile(path string, n int) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	var lines []string
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		lines = append(lines, strings.TrimRight(sc.Text(), "\r"))
+		if len(lines) > n {
+			lines = lines[1:]
+		}
+	}
+	return lines, sc.Err()
+}
@@ -0,0 +1,165 @@
+package worktree
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeScript(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "setup.sh")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// runWorker begins a background run and executes it in-process with its
+// output in the log, the way StartBackgroundSetup and `wtw setup-worker`
+// would.
+func runWorker(t *testing.T, cfg BackgroundSetupConfig) {
+	t.Helper()
+	run, err := beginSetupRun(cfg.WorktreePath, cfg.SetupScript, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.RunID = run.status.ID
+
+	stdout := os.Stdout
+	os.Stdout = run.log
+	err = RunBackgroundSetup(cfg)
+	os.Stdout = stdout
+	_ = run.log.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunBackgroundSetup_RecordsOutcome(t *testing.T) {
+	repo := setupRepo(t)
+	wt := createWorktree(t, repo, "feature/bg")
+
+	if _, ok, err := ReadSetupStatus(wt); ok || err != nil {
+		t.Fatalf("status before any setup: ok=%v err=%v", ok, err)
+	}
+
+	runWorker(t, BackgroundSetupConfig{
+		SetupScript:  writeScript(t, "echo hello from $BRANCH_NAME\n"),
+		WorktreePath: wt, BranchName: "feature/bg", RepoRoot: repo,
+	})
+	st, ok, err := ReadSetupStatus(wt)
+	if err != nil || !ok || st.State != SetupSucceeded || st.Finished.IsZero() {
+		t.Fatalf("status = %+v, ok=%v, err=%v", st, ok, err)
+	}
+	tail, _ := tailFile(st.Log, 10)
+	if !strings.Contains(strings.Join(tail, "\n"), "hello from feature/bg") {
+		t.Errorf("log tail = %q", tail)
+	}
+
+	runWorker(t, BackgroundSetupConfig{
+		SetupScript:  writeScript(t, "exit 4\n"),
+		WorktreePath: wt, BranchName: "feature/bg", RepoRoot: repo,
+	})
+	st, _, _ = ReadSetupStatus(wt)
+	if st.State != SetupFailed || !strings.Contains(st.Error, "exit status 4") {
+		t.Errorf("status = %+v", st)
+	}
+	if err := Wait(SetupStatusConfig{WorktreePath: wt}); err == nil {
+		t.Error("Wait on a failed setup should return an error")
+	}
+}
+
+func TestReadSetupStatus_DeadWorker(t *testing.T) {
+	repo := setupRepo(t)
+	wt := createWorktree(t, repo, "dead")
+
+	// A pid that is certainly gone: a finished child of ours.
+	p, err := os.StartProcess("/bin/sh", []string{"sh", "-c", "exit 0"}, &os.ProcAttr{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = p.Wait()
+
+	run, err := beginSetupRun(wt, "setup.sh", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run.status.PID = p.Pid
+	if err := run.save(); err != nil {
+		t.Fatal(err)
+	}
+	st, _, _ := ReadSetupStatus(wt)
+	if st.State != SetupFailed {
+		t.Errorf("state = %q, want failed", st.State)
+	}
+}
+
+func TestRecordWorker(t *testing.T) {
+	repo := setupRepo(t)
+	wt := createWorktree(t, repo, "record")
+
+	p, err := os.StartProcess("/bin/sh", []string{"sh", "-c", "exit 0"}, &os.ProcAttr{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = p.Wait()
+
+	// The worker died before taking the run over.
+	run, err := beginSetupRun(wt, "setup.sh", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := run.recordWorker(p.Pid); err != nil {
+		t.Fatal(err)
+	}
+	if st, _, _ := ReadSetupStatus(wt); st.State != SetupFailed {
+		t.Errorf("state = %q, want failed", st.State)
+	}
+
+	// The worker finished before the launcher got to record it.
+	run, err = beginSetupRun(wt, "setup.sh", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worker, err := resumeSetupRun(wt, run.status.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := worker.finish(nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := run.recordWorker(p.Pid); err != nil {
+		t.Fatal(err)
+	}
+	if st, _, _ := ReadSetupStatus(wt); st.State != SetupSucceeded || st.PID != 0 {
+		t.Errorf("status = %+v, want the worker's result", st)
+	}
+}
+
+func TestWait_BlocksUntilDone(t *testing.T) {
+	repo := setupRepo(t)
+	wt := createWorktree(t, repo, "waiting")
+
+	orig := waitInterval
+	waitInterval = 10 * time.Millisecond
+	t.Cleanup(func() { waitInterval = orig })
+
+	run, err := beginSetupRun(wt, "setup.sh", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Wait(SetupStatusConfig{WorktreePath: wt, Timeout: 50 * time.Millisecond}); err == nil {
+		t.Fatal("Wait should time out while setup is running")
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = run.finish(nil, nil)
+	}()
+	if err := Wait(SetupStatusConfig{WorktreePath: wt, Timeout: 5 * time.Second}); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+}
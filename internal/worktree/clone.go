@@ -0,0 +1,249 @@
+package worktree
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"wtw/internal/ui"
+)
+
+var errReflinkUnsupported = errors.New("reflinks not supported")
+
+// Clone methods, in order of preference.
+const (
+	cloneReflink  = "reflink"
+	cloneHardlink = "hardlink"
+	cloneCopy     = "copy"
+)
+
+// DepDir is a heavy dependency directory that can be cloned from the main
+// repo when its lockfiles are identical in both trees.
+type DepDir struct {
+	Dir       string   `json:"dir"`       // e.g. "node_modules"
+	Lockfiles []string `json:"lockfiles"` // e.g. "package-lock.json"; at least one must exist
+}
+
+// DefaultDepDirs is used when no wtw.clone entries are configured. Python
+// virtualenvs are left out: a venv hardcodes its absolute path in
+// bin/activate and its scripts' shebangs, so a copy would keep installing
+// into the main repo's venv.
+var DefaultDepDirs = []DepDir{
+	{Dir: "node_modules", Lockfiles: []string{"package-lock.json", "yarn.lock", "pnpm-lock.yaml", "bun.lockb"}},
+	{Dir: "vendor", Lockfiles: []string{"composer.lock"}},
+}
+
+// ParseDepDir parses a wtw.clone entry of the form "dir:lock1,lock2".
+func ParseDepDir(s string) (DepDir, error) {
+	dir, locks, ok := strings.Cut(s, ":")
+	dir = strings.TrimSpace(dir)
+	if !ok || dir == "" || strings.TrimSpace(locks) == "" {
+		return DepDir{}, fmt.Errorf("invalid clone entry %q: expected dir:lockfile[,lockfile...]", s)
+	}
+	d := DepDir{Dir: dir}
+	for _, l := range strings.Split(locks, ",") {
+		if l = strings.TrimSpace(l); l != "" {
+			d.Lockfiles = append(d.Lockfiles, l)
+		}
+	}
+	return d, nil
+}
+
+// CloneConfig holds inputs for CloneDeps.
+type CloneConfig struct {
+	Dirs     []DepDir `json:"dirs"`
+	Fallback string   `json:"fallback,omitempty"` // method when reflinks are unavailable: "none" (default), "copy" or "hardlink"
+}
+
+// cloneStats summarises one cloned directory.
+type cloneStats struct {
+	files  int
+	bytes  int64
+	method string // the method used for the bulk of the files
+}
+
+// CloneDeps clones dependency directories from the main repo into a new
+// worktree when the worktree's lockfiles match the main repo's, so package
+// installs start from a warm tree. Reflinks are used when the filesystem
+// supports them; otherwise cfg.Fallback decides.
+func CloneDeps(cfg CloneConfig, repoRoot, worktreePath string) error {
+	_, err := cloneDeps(cfg, repoRoot, worktreePath)
+	return err
+}
+
+// cloneDeps does the work for CloneDeps and returns the directories it
+// cloned, including those cloned before an error.
+func cloneDeps(cfg CloneConfig, repoRoot, worktreePath string) ([]string, error) {
+	fallback := cfg.Fallback
+	if fallback == "" {
+		// A full copy costs the disk space cloning is meant to save, and
+		// hardlinks let installs in the worktree modify the main repo's files.
+		fallback = "none"
+	}
+	if fallback != cloneCopy && fallback != cloneHardlink && fallback != "none" {
+		return nil, fmt.Errorf("unknown clone fallback %q (want copy, hardlink or none)", fallback)
+	}
+
+	var cloned []string
+	var saved int64
+	for _, d := range cfg.Dirs {
+		src := filepath.Join(repoRoot, d.Dir)
+		dst := filepath.Join(worktreePath, d.Dir)
+		if info, err := os.Stat(src); err != nil || !info.IsDir() {
+			continue
+		}
+		if _, err := os.Lstat(dst); err == nil {
+			continue
+		}
+		same, err := lockfilesMatch(d.Lockfiles, repoRoot, worktreePath)
+		if err != nil {
+			return cloned, err
+		}
+		if !same {
+			fmt.Printf("  %s: lockfile differs from the main repo, not cloned\n", d.Dir)
+			continue
+		}
+
+		st, err := cloneTree(src, dst, fallback)
+		if err != nil {
+			_ = os.RemoveAll(dst)
+		}
+		if errors.Is(err, errReflinkUnsupported) {
+			fmt.Printf("  %s: reflinks not supported here, not cloned\n", d.Dir)
+			continue
+		}
+		if err != nil {
+			return cloned, fmt.Errorf("cloning %s: %w", d.Dir, err)
+		}
+		cloned = append(cloned, d.Dir)
+
+		msg := fmt.Sprintf("%s: cloned %d files (%s) via %s", d.Dir, st.files, formatBytes(st.bytes), st.method)
+		if st.method != cloneCopy {
+			saved += st.bytes
+			msg += ", " + formatBytes(st.bytes) + " saved"
+		}
+		ui.Success(msg)
+	}
+	if saved > 0 {
+		fmt.Printf("  %s of disk space saved in total.\n", formatBytes(saved))
+	}
+	return cloned, nil
+}
+
+// lockfilesMatch reports whether the lockfiles present in the main repo are
+// present and byte-identical in the worktree. It is false when none exist.
+func lockfilesMatch(lockfiles []string, repoRoot, worktreePath string) (bool, error) {
+	found := false
+	for _, l := range lockfiles {
+		a, errA := fileHash(filepath.Join(repoRoot, l))
+		b, errB := fileHash(filepath.Join(worktreePath, l))
+		if errors.Is(errA, os.ErrNotExist) && errors.Is(errB, os.ErrNotExist) {
+			continue
+		}
+		if errA != nil || errB != nil {
+			if errors.Is(errA, os.ErrNotExist) || errors.Is(errB, os.ErrNotExist) {
+				return false, nil
+			}
+			return false, errors.Join(errA, errB)
+		}
+		if a != b {
+			return false, nil
+		}
+		found = true
+	}
+	return found, nil
+}
+
+// fileHash returns the sha256 of a file's content.
+func fileHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// cloneTree copies the tree at src to dst, cloning files with reflinks while
+// the filesystem allows it and switching to fallback at the first refusal.
+// With fallback "none" it returns errReflinkUnsupported instead.
+func cloneTree(src, dst, fallback string) (cloneStats, error) {
+	st := cloneStats{method: cloneReflink}
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case !info.Mode().IsRegular():
+			return nil
+		}
+
+		if st.method == cloneReflink {
+			err := reflinkFile(path, target, info.Mode().Perm())
+			if !errors.Is(err, errReflinkUnsupported) {
+				if err == nil {
+					st.files++
+					st.bytes += info.Size()
+				}
+				return err
+			}
+			if fallback == "none" {
+				return errReflinkUnsupported
+			}
+			st.method = fallback
+		}
+
+		if st.method == cloneHardlink {
+			err = os.Link(path, target)
+		} else {
+			err = copyFile(path, target, info.Mode().Perm())
+		}
+		if err == nil {
+			st.files++
+			st.bytes += info.Size()
+		}
+		return err
+	})
+	return st, err
+}
+
+// formatBytes renders n as a human-readable size, e.g. "1.2 GB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,133 @@
+package worktree
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTree writes files (path → content) under root.
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestParseDepDir(t *testing.T) {
+	d, err := ParseDepDir("node_modules: package-lock.json, yarn.lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Dir != "node_modules" || len(d.Lockfiles) != 2 || d.Lockfiles[1] != "yarn.lock" {
+		t.Errorf("ParseDepDir = %+v", d)
+	}
+	for _, bad := range []string{"node_modules", ":x", "vendor:"} {
+		if _, err := ParseDepDir(bad); err == nil {
+			t.Errorf("ParseDepDir(%q): expected error", bad)
+		}
+	}
+}
+
+func TestLockfilesMatch(t *testing.T) {
+	repo, wt := t.TempDir(), t.TempDir()
+	locks := []string{"package-lock.json", "yarn.lock"}
+
+	if same, _ := lockfilesMatch(locks, repo, wt); same {
+		t.Error("no lockfiles should not count as a match")
+	}
+
+	writeTree(t, repo, map[string]string{"package-lock.json": "v1"})
+	writeTree(t, wt, map[string]string{"package-lock.json": "v1"})
+	if same, err := lockfilesMatch(locks, repo, wt); !same || err != nil {
+		t.Errorf("identical lockfiles: %v, %v", same, err)
+	}
+
+	writeTree(t, wt, map[string]string{"package-lock.json": "v2"})
+	if same, _ := lockfilesMatch(locks, repo, wt); same {
+		t.Error("different lockfiles matched")
+	}
+
+	writeTree(t, wt, map[string]string{"package-lock.json": "v1", "yarn.lock": "y"})
+	if same, _ := lockfilesMatch(locks, repo, wt); same {
+		t.Error("lockfile present only in the worktree matched")
+	}
+}
+
+func TestCloneDeps(t *testing.T) {
+	for _, fallback := range []string{cloneCopy, cloneHardlink} {
+		t.Run(fallback, func(t *testing.T) {
+			repo, wt := t.TempDir(), t.TempDir()
+			writeTree(t, repo, map[string]string{
+				"composer.lock":        "lock",
+				"vendor/a/lib.php":     "<?php",
+				"vendor/b/c/other.php": "<?php // other",
+			})
+			writeTree(t, wt, map[string]string{"composer.lock": "lock"})
+			if err := os.Symlink("a/lib.php", filepath.Join(repo, "vendor", "link.php")); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg := CloneConfig{Dirs: []DepDir{{Dir: "vendor", Lockfiles: []string{"composer.lock"}}}, Fallback: fallback}
+			if err := CloneDeps(cfg, repo, wt); err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := os.ReadFile(filepath.Join(wt, "vendor", "b", "c", "other.php"))
+			if err != nil || string(data) != "<?php // other" {
+				t.Errorf("other.php = %q, %v", data, err)
+			}
+			if link, err := os.Readlink(filepath.Join(wt, "vendor", "link.php")); err != nil || link != "a/lib.php" {
+				t.Errorf("link.php = %q, %v", link, err)
+			}
+		})
+	}
+}
+
+func TestCloneDeps_OnlyReflinksByDefault(t *testing.T) {
+	repo, wt := t.TempDir(), t.TempDir()
+	writeTree(t, repo, map[string]string{"composer.lock": "lock", "vendor/x": "x"})
+	writeTree(t, wt, map[string]string{"composer.lock": "lock"})
+
+	cfg := CloneConfig{Dirs: []DepDir{{Dir: "vendor", Lockfiles: []string{"composer.lock"}}}}
+	out := captureStdout(t, func() error { return CloneDeps(cfg, repo, wt) })
+	if strings.Contains(out, "via "+cloneCopy) || strings.Contains(out, "via "+cloneHardlink) {
+		t.Errorf("cloned without reflinks by default:\n%s", out)
+	}
+}
+
+func TestCloneDeps_LockfileMismatch(t *testing.T) {
+	repo, wt := t.TempDir(), t.TempDir()
+	writeTree(t, repo, map[string]string{"composer.lock": "old", "vendor/x": "x"})
+	writeTree(t, wt, map[string]string{"composer.lock": "new"})
+
+	cfg := CloneConfig{Dirs: []DepDir{{Dir: "vendor", Lockfiles: []string{"composer.lock"}}}}
+	if err := CloneDeps(cfg, repo, wt); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(wt, "vendor")); err == nil {
+		t.Error("vendor cloned despite a lockfile mismatch")
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	cases := map[int64]string{
+		0:               "0 B",
+		1023:            "1023 B",
+		1536:            "1.5 KB",
+		5 * 1024 * 1024: "5.0 MB",
+		3 << 30:         "3.0 GB",
+	}
+	for n, want := range cases {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
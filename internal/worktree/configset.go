@@ -0,0 +1,59 @@
+package worktree
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"wtw/internal/configset"
+)
+
+// ConfigSetConfig holds inputs for ConfigSet.
+type ConfigSetConfig struct {
+	File  string
+	Pairs []string // path.to.key=value entries
+	Type  string   // type of every value: string (default), int, bool or json
+}
+
+// ConfigSet sets keys in a JSON, YAML or TOML file, chosen by its extension.
+// Missing keys and the objects leading to them are created.
+func ConfigSet(cfg ConfigSetConfig) error {
+	format, err := configset.FormatOf(cfg.File)
+	if err != nil {
+		return err
+	}
+	assignments := make([]configset.Assignment, 0, len(cfg.Pairs))
+	for _, p := range cfg.Pairs {
+		key, raw, ok := strings.Cut(p, "=")
+		if !ok {
+			return fmt.Errorf("invalid pair %q: expected path.to.key=VALUE", p)
+		}
+		path, err := configset.ParsePath(key)
+		if err != nil {
+			return err
+		}
+		value, err := configset.ParseValue(raw, cfg.Type)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		assignments = append(assignments, configset.Assignment{Path: path, Value: value})
+	}
+
+	info, err := os.Stat(cfg.File)
+	if err != nil {
+		return fmt.Errorf("cannot read %s: %w", cfg.File, err)
+	}
+	data, err := os.ReadFile(cfg.File)
+	if err != nil {
+		return fmt.Errorf("cannot read %s: %w", cfg.File, err)
+	}
+	out, err := configset.Set(format, data, assignments)
+	if err != nil {
+		return fmt.Errorf("%s: %w", cfg.File, err)
+	}
+	if bytes.Equal(out, data) {
+		return nil
+	}
+	return os.WriteFile(cfg.File, out, info.Mode())
+}
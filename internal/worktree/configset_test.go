@@ -0,0 +1,43 @@
+package worktree
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigSet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte("[server]\nport = 3000 # dev\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	err := ConfigSet(ConfigSetConfig{File: path, Pairs: []string{"server.port=4000", "server.tls=true"}, Type: "int"})
+	if err == nil || !strings.Contains(err.Error(), `server.tls: "true" is not an int`) {
+		t.Fatalf("err = %v, want a type error", err)
+	}
+	if err := ConfigSet(ConfigSetConfig{File: path, Pairs: []string{"server.port=4000"}, Type: "int"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ConfigSet(ConfigSetConfig{File: path, Pairs: []string{"server.host=a=b"}}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "[server]\nport = 4000 # dev\nhost = \"a=b\"\n"; string(data) != want {
+		t.Errorf("got %q, want %q", data, want)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	if err := ConfigSet(ConfigSetConfig{File: path, Pairs: []string{"port"}}); err == nil {
+		t.Error("a pair without = succeeded")
+	}
+	if err := ConfigSet(ConfigSetConfig{File: filepath.Join(t.TempDir(), "a.ini"), Pairs: []string{"a=1"}}); err == nil {
+		t.Error("an unknown format succeeded")
+	}
+}
@@ -0,0 +1,186 @@
+package worktree
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"wtw/internal/dotenv"
+	"wtw/internal/filelock"
+	"wtw/internal/git"
+	"wtw/internal/interp"
+)
+
+// EnvSetConfig holds inputs for EnvSet.
+type EnvSetConfig struct {
+	File  string      // path to the env file
+	Pairs []string    // KEY=VALUE entries
+	Mode  interp.Mode // how ${...} in values is expanded; Literal by default
+	Vars  []string    // KEY=VALUE worktree variables for expansion
+}
+
+// EnvSet sets or adds key-value pairs in an env file.
+// Existing keys are replaced in place, keeping their `export` prefix, inline
+// comment and quoting; absent keys are appended at the end. Values are
+// quoted when they need it, and the rest of the file is left untouched.
+//
+// Unless cfg.Mode is Literal, ${...} references in values are expanded from
+// the keys already in the file, including earlier pairs, then cfg.Vars, then
+// the process environment.
+func EnvSet(cfg EnvSetConfig) error {
+	type pair struct{ key, value string }
+	pairs := make([]pair, 0, len(cfg.Pairs))
+	for _, p := range cfg.Pairs {
+		key, value, ok := strings.Cut(p, "=")
+		if !ok {
+			return fmt.Errorf("invalid pair %q: expected KEY=VALUE", p)
+		}
+		pairs = append(pairs, pair{key, value})
+	}
+
+	return updateEnvFile(cfg.File, func(f *dotenv.File) (bool, error) {
+		lookup := interp.Chain(f.Get, interp.Vars(cfg.Vars), os.LookupEnv)
+		for _, p := range pairs {
+			value, err := interp.Interpolate(p.value, cfg.Mode, lookup)
+			if err != nil {
+				return false, fmt.Errorf("%s: %w", p.key, err)
+			}
+			if err := f.Set(p.key, value); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	})
+}
+
+// EnvGetConfig holds inputs for EnvGet.
+type EnvGetConfig struct {
+	File       string
+	Key        string
+	Default    string // printed when the key is missing, if HasDefault
+	HasDefault bool
+}
+
+// EnvGet prints the value of a key in an env file. A missing key is an
+// error unless a default is given.
+func EnvGet(cfg EnvGetConfig) error {
+	f, err := loadEnvFile(cfg.File)
+	if err != nil {
+		return err
+	}
+	value, ok := f.Get(cfg.Key)
+	if !ok {
+		if !cfg.HasDefault {
+			return fmt.Errorf("%s is not set in %s", cfg.Key, cfg.File)
+		}
+		value = cfg.Default
+	}
+	fmt.Println(value)
+	return nil
+}
+
+// EnvUnsetConfig holds inputs for EnvUnset.
+type EnvUnsetConfig struct {
+	File string
+	Keys []string
+}
+
+// EnvUnset removes keys from an env file. Keys that are not set are ignored.
+func EnvUnset(cfg EnvUnsetConfig) error {
+	return updateEnvFile(cfg.File, func(f *dotenv.File) (bool, error) {
+		changed := false
+		for _, key := range cfg.Keys {
+			if f.Unset(key) {
+				changed = true
+			}
+		}
+		return changed, nil
+	})
+}
+
+// EnvListConfig holds inputs for EnvList.
+type EnvListConfig struct {
+	File string
+	JSON bool
+}
+
+// EnvList prints the keys of an env file with their values, as KEY=VALUE
+// lines in file order, or as a JSON object.
+func EnvList(cfg EnvListConfig) error {
+	f, err := loadEnvFile(cfg.File)
+	if err != nil {
+		return err
+	}
+	keys := f.Keys()
+	if cfg.JSON {
+		values := make(map[string]string, len(keys))
+		for _, key := range keys {
+			values[key], _ = f.Get(key)
+		}
+		data, err := json.MarshalIndent(values, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	for _, key := range keys {
+		value, _ := f.Get(key)
+		fmt.Println(key + "=" + dotenv.Quote(value))
+	}
+	return nil
+}
+
+// loadEnvFile reads and parses an env file.
+func loadEnvFile(path string) (*dotenv.File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %w", path, err)
+	}
+	return dotenv.Parse(data), nil
+}
+
+// updateEnvFile loads the env file at path, applies fn and saves the result
+// if fn reports a change, all under the file's lock, so concurrent editors
+// cannot lose each other's keys. A symlinked file is locked and written
+// through the link, so worktrees sharing one file share its lock too.
+func updateEnvFile(path string, fn func(*dotenv.File) (changed bool, err error)) error {
+	real, err := filepath.EvalSymlinks(path)
+	if err == nil {
+		real, err = filepath.Abs(real)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot read %s: %w", path, err)
+	}
+	unlock, err := filelock.Lock(envLockPath(real))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = unlock() }()
+
+	f, err := loadEnvFile(path)
+	if err != nil {
+		return err
+	}
+	changed, err := fn(f)
+	if err != nil || !changed {
+		return err
+	}
+	return filelock.WriteFile(path, f.Bytes(), 0o644)
+}
+
+// envLockPath returns the lock file for the env file at path. Inside a
+// repository it lives under the git dir, named by a hash of the path, so
+// worktrees get no untracked files; elsewhere it is a <path>.lock sidecar.
+func envLockPath(path string) string {
+	common, err := git.CommonDir(filepath.Dir(path))
+	if err != nil {
+		return path + ".lock"
+	}
+	sum := sha256.Sum256([]byte(path))
+	return filepath.Join(common, "wtw", "locks", hex.EncodeToString(sum[:])[:16]+".lock")
+}
@@ -0,0 +1,185 @@
+package worktree
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"wtw/internal/dotenv"
+	"wtw/internal/git"
+	"wtw/internal/interp"
+)
+
+func TestEnvGet(t *testing.T) {
+	path := writeEnvFile(t, t.TempDir(), "export DB_DATABASE=\"app db\" # quoted\nEMPTY=\n")
+
+	out := captureStdout(t, func() error { return EnvGet(EnvGetConfig{File: path, Key: "DB_DATABASE"}) })
+	if out != "app db\n" {
+		t.Errorf("got %q", out)
+	}
+	out = captureStdout(t, func() error { return EnvGet(EnvGetConfig{File: path, Key: "EMPTY", Default: "x", HasDefault: true}) })
+	if out != "\n" {
+		t.Errorf("empty value: got %q", out)
+	}
+	out = captureStdout(t, func() error {
+		return EnvGet(EnvGetConfig{File: path, Key: "MISSING", Default: "fallback", HasDefault: true})
+	})
+	if out != "fallback\n" {
+		t.Errorf("default: got %q", out)
+	}
+	if err := EnvGet(EnvGetConfig{File: path, Key: "MISSING"}); err == nil || !strings.Contains(err.Error(), "MISSING is not set") {
+		t.Errorf("err = %v, want a missing key error", err)
+	}
+}
+
+func TestEnvUnset(t *testing.T) {
+	path := writeEnvFile(t, t.TempDir(), "# keep\nA=1\nB=2\nA=3\nC=4\n")
+
+	if err := EnvUnset(EnvUnsetConfig{File: path, Keys: []string{"A", "C", "MISSING"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readEnvFile(t, path); got != "# keep\nB=2\n" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestEnvList(t *testing.T) {
+	path := writeEnvFile(t, t.TempDir(), "B=1\nA='two words'\nB=3\n")
+
+	out := captureStdout(t, func() error { return EnvList(EnvListConfig{File: path}) })
+	if out != "B=3\nA=\"two words\"\n" {
+		t.Errorf("got %q", out)
+	}
+	out = captureStdout(t, func() error { return EnvList(EnvListConfig{File: path, JSON: true}) })
+	if out != "{\n  \"A\": \"two words\",\n  \"B\": \"3\"\n}\n" {
+		t.Errorf("json: got %q", out)
+	}
+}
+
+func TestEnvSet_Interpolates(t *testing.T) {
+	path := writeEnvFile(t, t.TempDir(), "DB_HOST=localhost\n")
+	t.Setenv("WTW_TEST_USER", "app")
+	vars := []string{"BRANCH_NAME=feature/Login", "WTW_PORT=41000"}
+
+	err := EnvSet(EnvSetConfig{
+		File: path,
+		Pairs: []string{
+			"DB_DATABASE=${BRANCH_NAME|snake|max:7}",
+			"DB_URL=postgres://${WTW_TEST_USER}@${DB_HOST}:${WTW_PORT}/${DB_DATABASE}",
+			"RAW=$${NOT_EXPANDED}",
+		},
+		Mode: interp.Expand,
+		Vars: vars,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "DB_HOST=localhost\nDB_DATABASE=feature\nDB_URL=postgres://app@localhost:41000/feature\nRAW=${NOT_EXPANDED}\n"
+	if got := readEnvFile(t, path); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	err = EnvSet(EnvSetConfig{File: path, Pairs: []string{"X=${NOPE}"}, Mode: interp.Strict, Vars: vars})
+	if !errors.Is(err, interp.ErrUndefined) {
+		t.Errorf("strict: err = %v, want ErrUndefined", err)
+	}
+	if err := EnvSet(EnvSetConfig{File: path, Pairs: []string{"X=${NOPE}"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readEnvFile(t, path); !strings.HasSuffix(got, "X=${NOPE}\n") {
+		t.Errorf("literal: got %q", got)
+	}
+}
+
+func TestEnvSet_ConcurrentWritersLoseNothing(t *testing.T) {
+	path := writeEnvFile(t, t.TempDir(), "# shared\nAPP=1\n")
+
+	const writers, rounds = 16, 10
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for r := 0; r < rounds; r++ {
+				pair := fmt.Sprintf("KEY_%d_%d=value %d", w, r, r)
+				if err := EnvSet(EnvSetConfig{File: path, Pairs: []string{pair}}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	f := dotenv.Parse([]byte(readEnvFile(t, path)))
+	if got, want := len(f.Keys()), writers*rounds+1; got != want {
+		t.Errorf("%d keys, want %d", got, want)
+	}
+	for w := 0; w < writers; w++ {
+		for r := 0; r < rounds; r++ {
+			if v, ok := f.Get(fmt.Sprintf("KEY_%d_%d", w, r)); !ok || v != fmt.Sprintf("value %d", r) {
+				t.Errorf("KEY_%d_%d = %q, %v", w, r, v, ok)
+			}
+		}
+	}
+}
+
+func TestEnvSet_KeepsModeAndSymlink(t *testing.T) {
+	dir := t.TempDir()
+	shared := writeEnvFile(t, t.TempDir(), "A=1\n")
+	if err := os.Chmod(shared, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, ".env")
+	if err := os.Symlink(shared, link); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := EnvSet(EnvSetConfig{File: link, Pairs: []string{"B=2"}}); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("%s is no longer a symlink: %v", link, err)
+	}
+	if got := readEnvFile(t, shared); got != "A=1\nB=2\n" {
+		t.Errorf("got %q", got)
+	}
+	if info, _ := os.Stat(shared); info.Mode().Perm() != 0o600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+	entries, _ := os.ReadDir(filepath.Dir(shared))
+	for _, e := range entries {
+		if strings.Contains(e.Name(), ".tmp-") {
+			t.Errorf("temp file %s left behind", e.Name())
+		}
+	}
+}
+
+func TestEnvSet_LocksUnderGitDir(t *testing.T) {
+	repo := setupRepo(t)
+	path := writeEnvFile(t, repo, "A=1\n")
+	if err := os.WriteFile(filepath.Join(repo, ".git", "info", "exclude"), []byte(".env\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := EnvSet(EnvSetConfig{File: path, Pairs: []string{"B=2"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := EnvUnset(EnvUnsetConfig{File: path, Keys: []string{"A"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readEnvFile(t, path); got != "B=2\n" {
+		t.Errorf("got %q", got)
+	}
+	if status, err := git.OutputIn(repo, "status", "--porcelain"); err != nil || status != "" {
+		t.Errorf("worktree not clean after editing .env: %q, %v", status, err)
+	}
+	locks, _ := filepath.Glob(filepath.Join(repo, ".git", "wtw", "locks", "*.lock"))
+	if len(locks) != 1 {
+		t.Errorf("locks under the git dir: %v, want one", locks)
+	}
+}
@@ -0,0 +1,182 @@
+package worktree
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"wtw/internal/git"
+)
+
+// DefaultSetupInputs are the files hashed into a setup fingerprint when no
+// wtw.setupInput entries are configured: the lockfiles of DefaultDepDirs.
+var DefaultSetupInputs = func() []string {
+	var inputs []string
+	for _, d := range DefaultDepDirs {
+		inputs = append(inputs, d.Lockfiles...)
+	}
+	return inputs
+}()
+
+// setupFingerprint hashes what a setup run depends on: the setup file (every
+// script of a .wtwrc.d directory), the interpreter and step selection, and
+// the input files and directories of spec, relative to the worktree. Inputs
+// may be glob patterns; missing inputs count as missing, not as an error.
+func setupFingerprint(spec setupSpec) (string, error) {
+	h := sha256.New()
+	scripts := []string{spec.script}
+	if isSetupDir(spec.script) {
+		var err error
+		if scripts, err = setupDirScripts(spec.script); err != nil {
+			return "", err
+		}
+	}
+	for _, s := range scripts {
+		sum, err := fileHash(s)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "script %s %s\n", filepath.Base(s), sum)
+	}
+	fmt.Fprintf(h, "interpreter %s\nonly %s\nskip %s\n", spec.interpreter,
+		strings.Join(spec.steps.Only, ","), strings.Join(spec.steps.Skip, ","))
+
+	inputs := spec.inputs
+	if inputs == nil {
+		inputs = DefaultSetupInputs
+	}
+	inputs = append([]string(nil), inputs...)
+	sort.Strings(inputs)
+	for _, in := range inputs {
+		matches, err := filepath.Glob(filepath.Join(spec.worktreePath, in))
+		if err != nil {
+			return "", fmt.Errorf("invalid setup input %q: %w", in, err)
+		}
+		if len(matches) == 0 {
+			fmt.Fprintf(h, "missing %s\n", in)
+			continue
+		}
+		for _, m := range matches {
+			if err := hashInput(h, spec.worktreePath, m); err != nil {
+				return "", err
+			}
+		}
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// hashInput writes the path and content hash of every file under path to h.
+func hashInput(h io.Writer, worktreePath, path string) error {
+	return filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(worktreePath, p)
+		rel = filepath.ToSlash(rel)
+		switch {
+		case d.IsDir():
+			return nil
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(h, "link %s %s\n", rel, link)
+		case d.Type().IsRegular():
+			sum, err := fileHash(p)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(h, "file %s %s\n", rel, sum)
+		}
+		return nil
+	})
+}
+
+// lastSucceededRun returns the newest setup run of a worktree that succeeded.
+func lastSucceededRun(worktreePath string) (SetupStatus, bool) {
+	runs, err := SetupRuns(worktreePath)
+	if err != nil {
+		return SetupStatus{}, false
+	}
+	for i := len(runs) - 1; i >= 0; i-- {
+		if runs[i].State == SetupSucceeded {
+			return runs[i], true
+		}
+	}
+	return SetupStatus{}, false
+}
+
+// setupUnchanged reports whether spec's fingerprint matches the last
+// successful setup run of its worktree, and returns that run.
+func setupUnchanged(spec setupSpec) (SetupStatus, bool) {
+	last, ok := lastSucceededRun(spec.worktreePath)
+	if !ok || last.Fingerprint == "" {
+		return last, false
+	}
+	fp, err := setupFingerprint(spec)
+	return last, err == nil && fp == last.Fingerprint
+}
+
+// Git hooks that run after `git pull` merges or rebases.
+var setupHooks = []struct {
+	name string
+	line string
+}{
+	{"post-merge", "command -v wtw >/dev/null 2>&1 && wtw run-wtwrc --if-changed --auto || true"},
+	{"post-rewrite", `[ "$1" = rebase ] && command -v wtw >/dev/null 2>&1 && wtw run-wtwrc --if-changed --auto || true`},
+}
+
+// setupHookMarker marks the lines wtw adds to a hook.
+const setupHookMarker = "# wtw: re-run worktree setup when its inputs changed"
+
+// InstallSetupHooks adds post-merge and post-rewrite hooks to the repo that
+// run `wtw run-wtwrc --if-changed` after a pull. Hooks are shared by every
+// worktree of the repo. Existing shell hooks are appended to.
+func InstallSetupHooks(repoRoot string) error {
+	dir, err := git.OutputIn(repoRoot, "rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return fmt.Errorf("cannot find the hooks directory: %w", err)
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(repoRoot, dir)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for _, hook := range setupHooks {
+		path := filepath.Join(dir, hook.name)
+		data, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		content := string(data)
+		switch {
+		case strings.Contains(content, setupHookMarker):
+			fmt.Println("  " + hook.name + ": already installed")
+			continue
+		case content == "":
+			content = "#!/bin/sh\n"
+		case !strings.HasPrefix(content, "#!/bin/sh") && !strings.HasPrefix(content, "#!/usr/bin/env sh") &&
+			!strings.Contains(strings.SplitN(content, "\n", 2)[0], "bash"):
+			return fmt.Errorf("%s is not a shell script; add `wtw run-wtwrc --if-changed --auto` to it yourself", path)
+		case !strings.HasSuffix(content, "\n"):
+			content += "\n"
+		}
+		content += "\n" + setupHookMarker + "\n" + hook.line + "\n"
+		if err := os.WriteFile(path, []byte(content), 0o755); err != nil {
+			return err
+		}
+		if err := os.Chmod(path, 0o755); err != nil {
+			return err
+		}
+		fmt.Println("  " + hook.name + ": installed at " + path)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package worktree
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetupFingerprint(t *testing.T) {
+	wt := t.TempDir()
+	writeTree(t, wt, map[string]string{
+		"package-lock.json":         "v1",
+		"migrations/001_init.sql":   "create table a;",
+		"migrations/002_second.sql": "create table b;",
+	})
+	script := writeScript(t, "npm ci\n")
+	spec := setupSpec{script: script, worktreePath: wt, inputs: []string{"package-lock.json", "migrations", "*.lock"}}
+	fp := func() string {
+		t.Helper()
+		s, err := setupFingerprint(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	base := fp()
+	if fp() != base {
+		t.Fatal("fingerprint is not stable")
+	}
+	changes := map[string]func(){
+		"lockfile":      func() { writeTree(t, wt, map[string]string{"package-lock.json": "v2"}) },
+		"new migration": func() { writeTree(t, wt, map[string]string{"migrations/003_third.sql": "x"}) },
+		"glob match":    func() { writeTree(t, wt, map[string]string{"composer.lock": "{}"}) },
+		"script":        func() { _ = os.WriteFile(script, []byte("npm install\n"), 0o755) },
+		"steps":         func() { spec.steps.Only = []string{"deps"} },
+	}
+	for _, name := range []string{"lockfile", "new migration", "glob match", "script", "steps"} {
+		prev := fp()
+		changes[name]()
+		if fp() == prev {
+			t.Errorf("fingerprint unchanged after changing the %s", name)
+		}
+	}
+
+	// Unrelated files don't matter.
+	prev := fp()
+	writeTree(t, wt, map[string]string{"README.md": "hi"})
+	if fp() != prev {
+		t.Error("fingerprint changed after touching an unrelated file")
+	}
+}
+
+func TestRunSetup_IfChanged(t *testing.T) {
+	repo := setupRepo(t)
+	wt := createWorktree(t, repo, "rerun")
+	if err := os.Chdir(wt); err != nil {
+		t.Fatal(err)
+	}
+	counter := filepath.Join(t.TempDir(), "runs")
+	script := writeScript(t, "echo run >> "+counter+"\n")
+	writeTree(t, wt, map[string]string{"composer.lock": "v1"})
+
+	run := func(cfg RunSetupConfig) {
+		t.Helper()
+		cfg.SetupScript, cfg.WorktreeRoot, cfg.MainRepoRoot, cfg.OriginalDir = script, wt, repo, wt
+		if err := RunSetup(cfg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runs := func() int {
+		data, _ := os.ReadFile(counter)
+		return strings.Count(string(data), "run\n")
+	}
+
+	run(RunSetupConfig{IfChanged: true}) // nothing recorded yet
+	run(RunSetupConfig{IfChanged: true})
+	if got := runs(); got != 1 {
+		t.Fatalf("ran %d times, want 1", got)
+	}
+	run(RunSetupConfig{IfChanged: true, Force: true})
+	if got := runs(); got != 2 {
+		t.Fatalf("--force: ran %d times, want 2", got)
+	}
+	writeTree(t, wt, map[string]string{"composer.lock": "v2"})
+	run(RunSetupConfig{IfChanged: true})
+	if got := runs(); got != 3 {
+		t.Fatalf("after a lockfile change: ran %d times, want 3", got)
+	}
+	run(RunSetupConfig{})
+	if got := runs(); got != 4 {
+		t.Fatalf("without --if-changed: ran %d times, want 4", got)
+	}
+}
+
+func TestRunSetup_IfChangedAfterFailure(t *testing.T) {
+	repo := setupRepo(t)
+	wt := createWorktree(t, repo, "flaky")
+	if err := os.Chdir(wt); err != nil {
+		t.Fatal(err)
+	}
+	script := writeScript(t, "true\n")
+	cfg := RunSetupConfig{SetupScript: script, WorktreeRoot: wt, MainRepoRoot: repo, OriginalDir: wt, IfChanged: true}
+	if err := RunSetup(cfg); err != nil {
+		t.Fatal(err)
+	}
+	// A failed run with the same inputs doesn't count as a change.
+	if err := os.WriteFile(script, []byte("exit 1\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := RunSetup(cfg); err == nil {
+		t.Fatal("expected the changed script to run and fail")
+	}
+	if err := RunSetup(cfg); err == nil {
+		t.Fatal("expected a re-run after the failure")
+	}
+}
+
+func TestInstallSetupHooks(t *testing.T) {
+	repo := setupRepo(t)
+	hooks := filepath.Join(repo, ".git", "hooks")
+	writeTree(t, hooks, map[string]string{"post-merge": "#!/bin/sh\necho existing"})
+
+	for i := 0; i < 2; i++ {
+		if err := InstallSetupHooks(repo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	merge, _ := os.ReadFile(filepath.Join(hooks, "post-merge"))
+	if !strings.HasPrefix(string(merge), "#!/bin/sh\necho existing\n") || strings.Count(string(merge), setupHookMarker) != 1 {
+		t.Errorf("post-merge = %q", merge)
+	}
+	info, err := os.Stat(filepath.Join(hooks, "post-rewrite"))
+	if err != nil || info.Mode().Perm()&0o100 == 0 {
+		t.Errorf("post-rewrite not installed as executable: %v", err)
+	}
+
+	writeTree(t, hooks, map[string]string{"post-merge": "#!/usr/bin/env node\n"})
+	if err := InstallSetupHooks(repo); err == nil {
+		t.Error("expected a non-shell hook to be refused")
+	}
+}
@@ -0,0 +1,222 @@
+package worktree
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"wtw/internal/git"
+	"wtw/internal/ui"
+)
+
+// IncludeFileName lists untracked files to bring over from the main repo.
+const IncludeFileName = ".worktreeinclude"
+
+// Include modes.
+const (
+	includeCopy     = "copy"
+	includeSymlink  = "symlink"
+	includeHardlink = "hardlink"
+)
+
+// includeRule is one section of a .worktreeinclude file: a mode and the
+// gitignore-style patterns it applies to.
+type includeRule struct {
+	mode      string
+	overwrite bool
+	patterns  []string
+}
+
+// parseIncludeFile parses a .worktreeinclude file. Patterns use gitignore
+// syntax and are copied by default; a "[symlink]", "[hardlink]" or "[copy]"
+// header switches the mode for the patterns that follow, and adding
+// "overwrite" (e.g. "[copy overwrite]") replaces files that already exist in
+// the worktree instead of skipping them.
+func parseIncludeFile(content string) ([]includeRule, error) {
+	rules := []includeRule{{mode: includeCopy}}
+	sc := bufio.NewScanner(strings.NewReader(content))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			fields := strings.Fields(line[1 : len(line)-1])
+			if len(fields) == 0 || len(fields) > 2 {
+				return nil, fmt.Errorf("line %d: invalid section %s", n, line)
+			}
+			r := includeRule{mode: fields[0]}
+			switch r.mode {
+			case includeCopy, includeSymlink, includeHardlink:
+			default:
+				return nil, fmt.Errorf("line %d: unknown mode %q (want copy, symlink or hardlink)", n, r.mode)
+			}
+			if len(fields) == 2 {
+				if fields[1] != "overwrite" {
+					return nil, fmt.Errorf("line %d: unknown option %q", n, fields[1])
+				}
+				r.overwrite = true
+			}
+			rules = append(rules, r)
+			continue
+		}
+		rules[len(rules)-1].patterns = append(rules[len(rules)-1].patterns, line)
+	}
+	return rules, sc.Err()
+}
+
+// includeSummary counts what ApplyIncludes did.
+type includeSummary struct {
+	brought []string // "path (mode)"
+	paths   []string // the brought paths, relative to the worktree
+	skipped int      // already present in the worktree
+}
+
+// ApplyIncludes brings untracked files matching .worktreeinclude from the
+// main repo into a new worktree and prints a summary. It is a no-op when the
+// repo has no .worktreeinclude.
+func ApplyIncludes(repoRoot, worktreePath string) error {
+	_, err := applyIncludes(repoRoot, worktreePath)
+	return err
+}
+
+// applyIncludes does the work for ApplyIncludes and returns the paths it
+// brought over, including those brought before an error.
+func applyIncludes(repoRoot, worktreePath string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(repoRoot, IncludeFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	rules, err := parseIncludeFile(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", IncludeFileName, err)
+	}
+
+	sum, err := applyIncludeRules(rules, repoRoot, worktreePath)
+	if err != nil {
+		return sum.paths, err
+	}
+	if len(sum.brought) == 0 && sum.skipped == 0 {
+		return nil, nil
+	}
+	ui.Success(fmt.Sprintf("Brought over %d path(s) from the main repo (%d already present).", len(sum.brought), sum.skipped))
+	for _, b := range sum.brought {
+		fmt.Println("  " + b)
+	}
+	return sum.paths, nil
+}
+
+// includeItem is one path that a rule brings over.
+type includeItem struct {
+	rel       string // slash-separated, relative to the repo root
+	mode      string
+	overwrite bool
+}
+
+// matchIncludes lists the untracked paths of the main repo that rules bring
+// over, each once, with the mode of the first rule that matches it.
+func matchIncludes(rules []includeRule, repoRoot string) ([]includeItem, error) {
+	var items []includeItem
+	done := map[string]bool{}
+	for _, r := range rules {
+		if len(r.patterns) == 0 {
+			continue
+		}
+		paths, err := git.UntrackedMatching(repoRoot, r.patterns)
+		if err != nil {
+			return nil, fmt.Errorf("failed to match %s patterns: %w", IncludeFileName, err)
+		}
+		for _, p := range paths {
+			rel := strings.TrimSuffix(p, "/")
+			if done[rel] {
+				continue
+			}
+			done[rel] = true
+			items = append(items, includeItem{rel: rel, mode: r.mode, overwrite: r.overwrite})
+		}
+	}
+	return items, nil
+}
+
+// applyIncludeRules does the work for ApplyIncludes.
+func applyIncludeRules(rules []includeRule, repoRoot, worktreePath string) (includeSummary, error) {
+	var sum includeSummary
+	items, err := matchIncludes(rules, repoRoot)
+	if err != nil {
+		return sum, err
+	}
+	for _, it := range items {
+		src := filepath.Join(repoRoot, filepath.FromSlash(it.rel))
+		dst := filepath.Join(worktreePath, filepath.FromSlash(it.rel))
+		if _, err := os.Lstat(dst); err == nil {
+			if !it.overwrite {
+				sum.skipped++
+				continue
+			}
+			if err := os.RemoveAll(dst); err != nil {
+				return sum, err
+			}
+		}
+		if err := includePath(it.mode, src, dst); err != nil {
+			return sum, fmt.Errorf("%s %s: %w", it.mode, it.rel, err)
+		}
+		sum.brought = append(sum.brought, fmt.Sprintf("%s (%s)", it.rel, it.mode))
+		sum.paths = append(sum.paths, it.rel)
+	}
+	return sum, nil
+}
+
+// includePath brings src to dst using mode.
+func includePath(mode, src, dst string) error {
+	switch mode {
+	case includeSymlink:
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return err
+		}
+		return os.Symlink(src, dst)
+	case includeHardlink:
+		return hardlinkPath(src, dst)
+	}
+	return copyPath(src, dst)
+}
+
+// hardlinkPath hard-links a file, or every file in a directory tree, from src
+// to dst. Symlinks are recreated rather than linked.
+func hardlinkPath(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		default:
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+			return os.Link(path, target)
+		}
+	})
+}
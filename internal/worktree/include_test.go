@@ -0,0 +1,115 @@
+package worktree
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseIncludeFile(t *testing.T) {
+	rules, err := parseIncludeFile(`# env files
+.env
+.env.*
+
+[symlink]
+node_modules/
+
+[hardlink overwrite]
+certs/*.pem
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 3 {
+		t.Fatalf("got %d rules, want 3", len(rules))
+	}
+	if rules[0].mode != includeCopy || len(rules[0].patterns) != 2 {
+		t.Errorf("rule 0 = %+v", rules[0])
+	}
+	if rules[1].mode != includeSymlink || rules[1].patterns[0] != "node_modules/" {
+		t.Errorf("rule 1 = %+v", rules[1])
+	}
+	if rules[2].mode != includeHardlink || !rules[2].overwrite {
+		t.Errorf("rule 2 = %+v", rules[2])
+	}
+
+	for _, bad := range []string{"[move]\n", "[copy force]\n", "[]\n"} {
+		if _, err := parseIncludeFile(bad); err == nil {
+			t.Errorf("parseIncludeFile(%q): expected error", bad)
+		}
+	}
+}
+
+func TestCreate_AppliesIncludes(t *testing.T) {
+	repoRoot := setupRepo(t)
+	files := map[string]string{
+		".gitignore":            ".env*\nnode_modules/\ncerts/\n",
+		IncludeFileName:         ".env*\n[symlink]\nnode_modules/\n[hardlink]\ncerts/*.pem\n",
+		".env":                  "A=1\n",
+		".env.local":            "B=2\n",
+		"node_modules/pkg/i.js": "x",
+		"certs/dev.pem":         "pem",
+		"certs/notes.txt":       "no",
+	}
+	for name, content := range files {
+		path := filepath.Join(repoRoot, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gitIn(t, repoRoot, "add", ".gitignore", IncludeFileName)
+	gitIn(t, repoRoot, "commit", "-m", "include")
+
+	wt := createWorktree(t, repoRoot, "inc")
+
+	if data, err := os.ReadFile(filepath.Join(wt, ".env.local")); err != nil || string(data) != "B=2\n" {
+		t.Errorf(".env.local = %q, %v", data, err)
+	}
+	if link, err := os.Readlink(filepath.Join(wt, "node_modules")); err != nil || link != filepath.Join(repoRoot, "node_modules") {
+		t.Errorf("node_modules link = %q, %v", link, err)
+	}
+	a, errA := os.Stat(filepath.Join(repoRoot, "certs", "dev.pem"))
+	b, errB := os.Stat(filepath.Join(wt, "certs", "dev.pem"))
+	if errA != nil || errB != nil || !os.SameFile(a, b) {
+		t.Errorf("certs/dev.pem not hard-linked: %v %v", errA, errB)
+	}
+	if _, err := os.Stat(filepath.Join(wt, "certs", "notes.txt")); err == nil {
+		t.Error("certs/notes.txt should not match certs/*.pem")
+	}
+}
+
+func TestApplyIncludeRules_SkipsExisting(t *testing.T) {
+	repoRoot := setupRepo(t)
+	wt := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repoRoot, ".env"), []byte("main"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(wt, ".env"), []byte("mine"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sum, err := applyIncludeRules([]includeRule{{mode: includeCopy, patterns: []string{".env"}}}, repoRoot, wt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum.skipped != 1 || len(sum.brought) != 0 {
+		t.Errorf("summary = %+v, want one skipped", sum)
+	}
+	if data, _ := os.ReadFile(filepath.Join(wt, ".env")); string(data) != "mine" {
+		t.Errorf("existing file overwritten: %q", data)
+	}
+
+	sum, err = applyIncludeRules([]includeRule{{mode: includeCopy, overwrite: true, patterns: []string{".env"}}}, repoRoot, wt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sum.brought) != 1 {
+		t.Errorf("summary = %+v, want one brought", sum)
+	}
+	if data, _ := os.ReadFile(filepath.Join(wt, ".env")); string(data) != "main" {
+		t.Errorf("overwrite did not replace file: %q", data)
+	}
+}
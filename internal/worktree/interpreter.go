@@ -0,0 +1,117 @@
+package worktree
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SetupDirName is a directory of setup scripts, run in name order. Each
+// script may be in a different language.
+const SetupDirName = ".wtwrc.d"
+
+// extInterpreters picks an interpreter for scripts without a shebang.
+var extInterpreters = map[string][]string{
+	".sh":   {"bash"},
+	".bash": {"bash"},
+	".zsh":  {"zsh"},
+	".fish": {"fish"},
+	".py":   {"python3"},
+	".rb":   {"ruby"},
+	".js":   {"node"},
+	".mjs":  {"node"},
+	".php":  {"php"},
+	".pl":   {"perl"},
+	".ps1":  {"pwsh", "-File"},
+}
+
+// scriptCommand returns the command line that runs script. In order of
+// preference it uses the configured interpreter, the script's shebang, an
+// interpreter picked by file extension, and finally bash (or sh where there
+// is no bash). Scripts never need to be executable.
+func scriptCommand(script, interpreter string) ([]string, error) {
+	if fields := strings.Fields(interpreter); len(fields) > 0 {
+		return append(fields, script), nil
+	}
+	shebang, err := readShebang(script)
+	if err != nil {
+		return nil, err
+	}
+	if len(shebang) > 0 {
+		return append(shebang, script), nil
+	}
+	if argv, ok := extInterpreters[strings.ToLower(filepath.Ext(script))]; ok {
+		return append(append([]string{}, argv...), script), nil
+	}
+	if _, err := exec.LookPath("bash"); err != nil {
+		return []string{"sh", script}, nil
+	}
+	return []string{"bash", script}, nil
+}
+
+// readShebang returns the interpreter and arguments named by a script's #!
+// line, or nil when it has none. "/usr/bin/env [-S] prog" becomes "prog", and
+// an absolute interpreter path that does not exist on this machine (say
+// /bin/bash on NixOS) is replaced by its base name, to be found on PATH.
+func readShebang(script string) ([]string, error) {
+	f, err := os.Open(script)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	line, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil && line == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(line, "#!") {
+		return nil, nil
+	}
+	fields := strings.Fields(line[2:])
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	if filepath.Base(fields[0]) == "env" {
+		fields = fields[1:]
+		if len(fields) > 0 && fields[0] == "-S" {
+			fields = fields[1:]
+		}
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("%s: shebang names no program", script)
+		}
+		return fields, nil
+	}
+	if _, err := os.Stat(fields[0]); errors.Is(err, os.ErrNotExist) {
+		fields[0] = filepath.Base(fields[0])
+	}
+	return fields, nil
+}
+
+// setupDirScripts returns the scripts in a .wtwrc.d directory in the order
+// they run. Hidden files, subdirectories and editor backups are ignored.
+func setupDirScripts(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var scripts []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") {
+			continue
+		}
+		scripts = append(scripts, filepath.Join(dir, name))
+	}
+	sort.Strings(scripts)
+	return scripts, nil
+}
+
+// isSetupDir reports whether path is a directory of setup scripts.
+func isSetupDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
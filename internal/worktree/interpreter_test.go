@@ -0,0 +1,116 @@
+package worktree
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScriptCommand(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name, content, interpreter string
+		want                       []string
+	}{
+		{".wtwrc", "echo hi\n", "", []string{"bash"}},
+		{".wtwrc", "#!/usr/bin/env python3\nprint(1)\n", "", []string{"python3"}},
+		{".wtwrc", "#!/usr/bin/env -S node --trace-warnings\n", "", []string{"node", "--trace-warnings"}},
+		{".wtwrc", "#!/bin/sh -e\n", "", []string{"/bin/sh", "-e"}},
+		{".wtwrc", "#!/no/such/dir/zsh\n", "", []string{"zsh"}},
+		{".wtwrc", "#!/usr/bin/env python3\n", "zsh -e", []string{"zsh", "-e"}},
+		{"20-deps.py", "import os\n", "", []string{"python3"}},
+		{"30-run.rb", "puts 1\n", "", []string{"ruby"}},
+		{"empty", "", "", []string{"bash"}},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.name)
+		if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := scriptCommand(path, tt.interpreter)
+		if err != nil {
+			t.Errorf("%q: %v", tt.content, err)
+			continue
+		}
+		want := append(tt.want, path)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%q (interpreter %q) = %q, want %q", tt.content, tt.interpreter, got, want)
+		}
+	}
+}
+
+func TestSetupDirScripts(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"20-deps.sh":   "",
+		"10-env.py":    "",
+		".hidden":      "",
+		"30-x.sh~":     "",
+		"sub/ignored":  "",
+		"15-keys.bash": "",
+	})
+	scripts, err := setupDirScripts(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, s := range scripts {
+		names = append(names, filepath.Base(s))
+	}
+	want := []string{"10-env.py", "15-keys.bash", "20-deps.sh"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("scripts = %v, want %v", names, want)
+	}
+}
+
+func TestRunScript_SetupDir(t *testing.T) {
+	repo := setupRepo(t)
+	writeTree(t, repo, map[string]string{
+		SetupDirName + "/10-first":  "#!/bin/sh\necho \"sh $BRANCH_NAME\" >> out.txt\n",
+		SetupDirName + "/20-second": "#!/usr/bin/env bash\necho \"bash ${BASH_VERSION:+yes}\" >> out.txt\n",
+	})
+	script, err := ResolveSetupScript("", repo)
+	if err != nil || script != filepath.Join(repo, SetupDirName) {
+		t.Fatalf("resolved %q, %v", script, err)
+	}
+	if err := RunScript(script, repo, "main", repo, repo); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(filepath.Join(repo, "out.txt"))
+	if got := string(data); got != "sh main\nbash yes\n" {
+		t.Errorf("out.txt = %q", got)
+	}
+
+	writeTree(t, repo, map[string]string{SetupDirName + "/15-fail": "exit 2\n"})
+	err = RunScript(script, repo, "main", repo, repo)
+	if err == nil || !strings.Contains(err.Error(), "15-fail") {
+		t.Errorf("err = %v, want failure naming 15-fail", err)
+	}
+}
+
+func TestEnsureTrusted_SetupDir(t *testing.T) {
+	isolateTrustStore(t)
+	repo := t.TempDir()
+	dir := filepath.Join(repo, SetupDirName)
+	writeTree(t, repo, map[string]string{
+		SetupDirName + "/10-a.sh": "echo a\n",
+		SetupDirName + "/20-b.py": "print('b')\n",
+	})
+
+	orig := isInteractive
+	t.Cleanup(func() { isInteractive = orig })
+	isInteractive = func() bool { return false }
+
+	if err := Trust(TrustConfig{RepoRoot: repo}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ensureTrusted(dir, repo); err != nil {
+		t.Fatalf("trusted directory refused: %v", err)
+	}
+	writeTree(t, repo, map[string]string{SetupDirName + "/30-new.sh": "echo new\n"})
+	if _, err := ensureTrusted(dir, repo); err == nil || !strings.Contains(err.Error(), "30-new.sh") {
+		t.Errorf("err = %v, want 30-new.sh untrusted", err)
+	}
+}
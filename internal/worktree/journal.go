@@ -0,0 +1,374 @@
+package worktree
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"wtw/internal/git"
+	"wtw/internal/ports"
+	"wtw/internal/ui"
+)
+
+// Creation steps, in the order Create takes them.
+const (
+	stepCheckout = "checkout"
+	stepPorts    = "ports"
+	stepInclude  = "include"
+	stepClone    = "clone"
+	stepSetup    = "setup"
+)
+
+// createJournal records the progress of a Create, so that `wtw resume` can
+// finish a worktree whose creation failed or was cut short. It is kept in
+// the worktree's git dir as wtw/create.json, together with the settings the
+// steps need, and deleted once every step is done.
+type createJournal struct {
+	Branch      string        `json:"branch"`
+	RepoRoot    string        `json:"repo_root"`
+	OriginalDir string        `json:"original_dir"`
+	Started     time.Time     `json:"started"`
+	Steps       []string      `json:"steps"`
+	Done        []string      `json:"done,omitempty"`
+	SetupScript string        `json:"setup_script,omitempty"`
+	SetupSteps  StepSelection `json:"setup_selection"`
+	SetupDone   []string      `json:"setup_done,omitempty"` // .wtw.yml steps or .wtwrc.d scripts that succeeded
+	Interpreter string        `json:"interpreter,omitempty"`
+	Timeout     time.Duration `json:"timeout,omitempty"`
+	Inputs      []string      `json:"inputs,omitempty"`
+	Clone       *CloneConfig  `json:"clone,omitempty"`
+	Ports       *ports.Config `json:"ports,omitempty"`
+}
+
+// newJournal returns the journal of a create plan, with nothing done yet.
+func newJournal(p *createPlan) *createJournal {
+	cfg := p.cfg
+	j := &createJournal{
+		Branch:      p.branch,
+		RepoRoot:    cfg.RepoRoot,
+		OriginalDir: cfg.OriginalDir,
+		Started:     time.Now(),
+		Steps:       []string{stepCheckout},
+		SetupScript: cfg.SetupScript,
+		SetupSteps:  cfg.Steps,
+		Interpreter: cfg.Interpreter,
+		Timeout:     cfg.SetupTimeout,
+		Inputs:      cfg.SetupInputs,
+		Clone:       cfg.Clone,
+		Ports:       cfg.Ports,
+	}
+	if cfg.Ports != nil {
+		j.Steps = append(j.Steps, stepPorts)
+	}
+	j.Steps = append(j.Steps, stepInclude)
+	if cfg.Clone != nil {
+		j.Steps = append(j.Steps, stepClone)
+	}
+	if cfg.SetupScript != "" {
+		j.Steps = append(j.Steps, stepSetup)
+	}
+	return j
+}
+
+// next returns the first step that is not done, or "" when all are.
+func (j *createJournal) next() string {
+	for _, s := range j.Steps {
+		if !slices.Contains(j.Done, s) {
+			return s
+		}
+	}
+	return ""
+}
+
+func journalPath(worktreePath string) (string, error) {
+	dir, err := setupDir(worktreePath)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "create.json"), nil
+}
+
+// readJournal returns the creation journal of a worktree. The error wraps
+// os.ErrNotExist when its creation finished.
+func readJournal(worktreePath string) (*createJournal, error) {
+	path, err := journalPath(worktreePath)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	j := &createJournal{}
+	if err := json.Unmarshal(data, j); err != nil {
+		return nil, fmt.Errorf("corrupt creation journal %s: %w", path, err)
+	}
+	return j, nil
+}
+
+// writeJournal saves j, or deletes it once every step is done.
+func writeJournal(worktreePath string, j *createJournal) error {
+	path, err := journalPath(worktreePath)
+	if err != nil {
+		return err
+	}
+	if j.next() == "" {
+		err := os.Remove(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// updateJournal applies fn to a worktree's journal, if it has one.
+func updateJournal(worktreePath string, fn func(*createJournal)) error {
+	j, err := readJournal(worktreePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fn(j)
+	return writeJournal(worktreePath, j)
+}
+
+// markStepDone records that a creation step finished.
+func markStepDone(worktreePath, step string) error {
+	return updateJournal(worktreePath, func(j *createJournal) {
+		if !slices.Contains(j.Done, step) {
+			j.Done = append(j.Done, step)
+		}
+	})
+}
+
+// recordSetupProgress records that one step of .wtw.yml, or one script of
+// .wtwrc.d, succeeded, so that resuming the creation does not run it again.
+func recordSetupProgress(spec setupSpec, name string) {
+	_ = updateJournal(spec.worktreePath, func(j *createJournal) {
+		if j.SetupScript == spec.script && !slices.Contains(j.SetupDone, name) {
+			j.SetupDone = append(j.SetupDone, name)
+		}
+	})
+}
+
+// recordSetupFinished marks the setup step of a creation done after the
+// same setup ran successfully, whether from Create, its background worker
+// or `wtw run-wtwrc`.
+func recordSetupFinished(spec setupSpec) {
+	_ = updateJournal(spec.worktreePath, func(j *createJournal) {
+		if j.SetupScript == spec.script && slices.Equal(j.SetupSteps.Only, spec.steps.Only) &&
+			slices.Equal(j.SetupSteps.Skip, spec.steps.Skip) && !slices.Contains(j.Done, stepSetup) {
+			j.Done = append(j.Done, stepSetup)
+		}
+	})
+}
+
+// incompleteStep returns the next step of a worktree whose creation has
+// not finished.
+func incompleteStep(worktreePath string) (string, bool) {
+	j, err := readJournal(worktreePath)
+	if err != nil {
+		return "", false
+	}
+	return j.next(), true
+}
+
+// runSteps takes the journal's steps that are not done yet, in order, and
+// marks each done as it succeeds. With cfg.Atomic any failure rolls back and
+// a failed checkout always stops; other failures are reported and the
+// remaining steps still run, leaving the failed ones for `wtw resume`.
+// failed reports whether any step failed that way.
+func (p *createPlan) runSteps(rb *rollback) (failed bool, err error) {
+	j, err := readJournal(p.path)
+	if err != nil {
+		return false, rb.fail(err)
+	}
+	for _, step := range j.Steps {
+		if slices.Contains(j.Done, step) {
+			continue
+		}
+		done, err := p.runStep(rb, step, j.SetupDone)
+		if ierr := rb.check(); ierr != nil {
+			return false, rb.fail(ierr)
+		}
+		if err != nil {
+			if p.cfg.Atomic {
+				return false, rb.fail(err)
+			}
+			if step == stepCheckout {
+				return false, fmt.Errorf("%w; fix the problem and run: wtw resume %s", err, p.branch)
+			}
+			ui.Error(err.Error())
+			if step == stepSetup {
+				fmt.Println("See the log with: wtw logs " + p.branch)
+			}
+			failed = true
+			continue
+		}
+		if done {
+			if err := markStepDone(p.path, step); err != nil {
+				return false, rb.fail(err)
+			}
+		}
+	}
+	return failed, nil
+}
+
+// runStep takes one creation step. done is false when the step failed or,
+// for setup, was left to a background worker or to a later resume.
+func (p *createPlan) runStep(rb *rollback, step string, setupDone []string) (done bool, err error) {
+	cfg := p.cfg
+	switch step {
+	case stepCheckout:
+		if err := git.Run(p.path, "checkout", "-f"); err != nil {
+			return false, fmt.Errorf("failed to check out %s: %w", p.branch, err)
+		}
+
+	case stepPorts:
+		a, err := ports.Allocate(*cfg.Ports, cfg.RepoRoot, p.path, p.branch)
+		if err != nil {
+			return false, fmt.Errorf("could not allocate ports: %w", err)
+		}
+		rb.add(fmt.Sprintf("freed ports %d-%d", a.Ports[0], a.Ports[len(a.Ports)-1]), func() error { return ports.Free(p.path) })
+		fmt.Printf("Ports %d-%d ($WTW_PORT=%d)\n", a.Ports[0], a.Ports[len(a.Ports)-1], a.Ports[0])
+
+	case stepInclude:
+		brought, err := applyIncludes(cfg.RepoRoot, p.path)
+		for _, rel := range brought {
+			rb.add("removed "+rel, removeUnder(p.path, rel))
+		}
+		if err != nil {
+			return false, fmt.Errorf("could not bring over %s files: %w", IncludeFileName, err)
+		}
+
+	case stepClone:
+		cloned, err := cloneDeps(*cfg.Clone, cfg.RepoRoot, p.path)
+		for _, dir := range cloned {
+			rb.add("removed "+dir, removeUnder(p.path, dir))
+		}
+		if err != nil {
+			return false, fmt.Errorf("could not clone dependencies: %w", err)
+		}
+
+	case stepSetup:
+		return p.runSetupStep(rb, setupDone)
+	}
+	return true, nil
+}
+
+// runSetupStep runs the setup script, skipping the parts in setupDone.
+func (p *createPlan) runSetupStep(rb *rollback, setupDone []string) (done bool, err error) {
+	cfg := p.cfg
+	var asked, run bool
+	rb.unlocked(func() { asked, err = ensureTrusted(cfg.SetupScript, cfg.RepoRoot) })
+	if err == nil && !asked {
+		rb.unlocked(func() { run = ui.Confirm("Found "+filepath.Base(cfg.SetupScript)+" — run it? [Y/n]", "Y") })
+	}
+	switch {
+	case err != nil && asked:
+		// The user declined the script: not a failure, but not done either,
+		// so that resuming after `wtw trust` runs it.
+		ui.Error("skipping setup: " + err.Error())
+		return false, nil
+	case err != nil:
+		return false, fmt.Errorf("skipping setup: %w", err)
+	case !asked && !run:
+		return true, nil
+	case cfg.Background:
+		logPath, err := StartBackgroundSetup(BackgroundSetupConfig{
+			SetupScript:  cfg.SetupScript,
+			WorktreePath: p.path,
+			BranchName:   p.branch,
+			RepoRoot:     cfg.RepoRoot,
+			OriginalDir:  cfg.OriginalDir,
+			Steps:        cfg.Steps,
+			Timeout:      cfg.SetupTimeout,
+			Interpreter:  cfg.Interpreter,
+			Inputs:       cfg.SetupInputs,
+		})
+		if err != nil {
+			return false, fmt.Errorf("could not start setup: %w", err)
+		}
+		fmt.Println("Setup is running in the background. Log: " + logPath)
+		fmt.Println("Check on it with: wtw status " + p.branch + "  (or block with: wtw wait " + p.branch + ")")
+		return false, nil // the worker records it when it succeeds
+	}
+
+	spec := p.setupSpec()
+	spec.done = setupDone
+	if err := runSetupFile(nil, spec); err != nil {
+		if errors.Is(err, ErrSetupTimedOut) || errors.Is(err, ErrSetupInterrupted) {
+			return false, err
+		}
+		return false, fmt.Errorf("setup failed: %w", err)
+	}
+	return true, nil
+}
+
+// ResumeConfig holds inputs for Resume.
+type ResumeConfig struct {
+	WorktreePath string
+}
+
+// Resume finishes the creation of a worktree from its first step that is not
+// done, with the settings it was created with.
+func Resume(cfg ResumeConfig) error {
+	j, err := readJournal(cfg.WorktreePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%s was created completely; nothing to resume", cfg.WorktreePath)
+	}
+	if err != nil {
+		return err
+	}
+	p := &createPlan{
+		branch: j.Branch,
+		path:   cfg.WorktreePath,
+		cfg: CreateConfig{
+			BranchName:   j.Branch,
+			SetupScript:  j.SetupScript,
+			RepoRoot:     j.RepoRoot,
+			OriginalDir:  j.OriginalDir,
+			Steps:        j.SetupSteps,
+			Clone:        j.Clone,
+			Ports:        j.Ports,
+			SetupTimeout: j.Timeout,
+			Interpreter:  j.Interpreter,
+			SetupInputs:  j.Inputs,
+		},
+	}
+	next := j.next()
+	if next == stepSetup && len(j.SetupDone) > 0 {
+		next += " (after " + strings.Join(j.SetupDone, ", ") + ")"
+	}
+	fmt.Printf("Resuming %s at %s.\n", p.branch, next)
+
+	rb := newRollback(false)
+	if _, err := p.runSteps(rb); err != nil {
+		return err
+	}
+	if step, ok := incompleteStep(p.path); ok {
+		return fmt.Errorf("creation of %s is still incomplete at %s; run wtw resume again once fixed", p.branch, step)
+	}
+	ui.Success("Worktree ready.")
+	ui.PrintCmd("cd " + p.path)
+	return nil
+}
@@ -0,0 +1,94 @@
+package worktree
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"wtw/internal/git"
+)
+
+func TestCreate_ResumesAtFailedSetupStep(t *testing.T) {
+	repo := setupRepo(t)
+	state := t.TempDir()
+	flag, runs := filepath.Join(state, "fixed"), filepath.Join(state, "runs")
+	steps := writeSteps(t, t.TempDir(), `
+steps:
+  - name: deps
+    run: echo deps >> `+runs+`
+  - name: migrate
+    run: test -f `+flag+`
+  - name: seed
+    run: echo seed >> `+runs+`
+`)
+	path := filepath.Join(filepath.Dir(repo), filepath.Base(repo)+"-resumable")
+	t.Cleanup(func() { _ = os.RemoveAll(path) })
+	if err := Create(CreateConfig{
+		BranchName:  "resumable",
+		RepoRoot:    repo,
+		RepoName:    filepath.Base(repo),
+		OriginalDir: repo,
+		SetupScript: steps,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	j, err := readJournal(path)
+	if err != nil {
+		t.Fatalf("no journal after a failed setup: %v", err)
+	}
+	if j.next() != stepSetup || strings.Join(j.SetupDone, ",") != "deps" {
+		t.Fatalf("journal at %q with %v done", j.next(), j.SetupDone)
+	}
+	out := captureStdout(t, func() error { return List(repo) })
+	if !strings.Contains(out, "incomplete at setup (wtw resume resumable)") {
+		t.Errorf("list does not flag the worktree:\n%s", out)
+	}
+
+	if err := os.WriteFile(flag, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Resume(ResumeConfig{WorktreePath: path}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(runs); string(data) != "deps\nseed\n" {
+		t.Errorf("runs = %q, want deps once then seed", data)
+	}
+	if _, err := readJournal(path); !os.IsNotExist(err) {
+		t.Errorf("journal left after resuming: %v", err)
+	}
+	if err := Resume(ResumeConfig{WorktreePath: path}); err == nil {
+		t.Error("expected nothing to resume")
+	}
+}
+
+func TestResume_ChecksOut(t *testing.T) {
+	repo := setupRepo(t)
+	commitFile(t, repo, "README.md", "hi")
+	p, err := planCreate(CreateConfig{BranchName: "cut-short", RepoRoot: repo, RepoName: filepath.Base(repo), OriginalDir: repo})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(p.path) })
+	// Stop where an interrupted Create would: added, journaled, not checked out.
+	if err := git.Run(repo, p.gitArgs()...); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeJournal(p.path, newJournal(p)); err != nil {
+		t.Fatal(err)
+	}
+	if step, ok := incompleteStep(p.path); !ok || step != stepCheckout {
+		t.Fatalf("incomplete step = %q, %v", step, ok)
+	}
+
+	if err := Resume(ResumeConfig{WorktreePath: p.path}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(p.path, "README.md")); err != nil {
+		t.Errorf("worktree not checked out: %v", err)
+	}
+	if _, ok := incompleteStep(p.path); ok {
+		t.Error("journal left after resuming")
+	}
+}
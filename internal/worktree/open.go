@@ -0,0 +1,135 @@
+package worktree
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// OpenMode selects how Open launches a worktree.
+type OpenMode string
+
+const (
+	OpenEditor   OpenMode = "editor"
+	OpenTmux     OpenMode = "tmux"
+	OpenTerminal OpenMode = "terminal"
+)
+
+// ParseOpenMode validates a mode name from a flag or config value.
+func ParseOpenMode(s string) (OpenMode, error) {
+	switch m := OpenMode(s); m {
+	case OpenEditor, OpenTmux, OpenTerminal:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown open mode %q (want editor, tmux or terminal)", s)
+}
+
+// OpenConfig holds inputs for Open.
+type OpenConfig struct {
+	Mode         OpenMode
+	EditorCmd    string // command template; empty falls back to $VISUAL/$EDITOR
+	TerminalCmd  string // command template; empty uses the platform default
+	WorktreePath string
+	BranchName   string
+	RepoRoot     string
+	OriginalDir  string
+}
+
+// Open launches an editor, tmux session/window or terminal on a worktree.
+// Command templates run through sh with the same variables as .wtwrc, e.g.
+// `code "$WORKTREE_PATH"`.
+func Open(cfg OpenConfig) error {
+	env := append(os.Environ(), scriptEnv(cfg.WorktreePath, cfg.BranchName, cfg.RepoRoot, cfg.OriginalDir)...)
+
+	switch cfg.Mode {
+	case OpenEditor:
+		tmpl, err := editorCommand(cfg.EditorCmd, os.Getenv("VISUAL"), os.Getenv("EDITOR"))
+		if err != nil {
+			return err
+		}
+		return runTemplate(tmpl, cfg.WorktreePath, env)
+	case OpenTerminal:
+		tmpl, err := terminalCommand(cfg.TerminalCmd, runtime.GOOS)
+		if err != nil {
+			return err
+		}
+		return runTemplate(tmpl, cfg.WorktreePath, env)
+	case OpenTmux:
+		if _, err := exec.LookPath("tmux"); err != nil {
+			return errors.New("tmux not found in PATH")
+		}
+		name := tmuxName(filepath.Base(cfg.WorktreePath))
+		inside := os.Getenv("TMUX") != ""
+		exists := inside && exec.Command("tmux", "select-window", "-t", ":="+name).Run() == nil
+		if exists {
+			return nil
+		}
+		cmd := exec.Command("tmux", tmuxArgs(inside, name, cfg.WorktreePath)...)
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		cmd.Env = env
+		return cmd.Run()
+	}
+	return fmt.Errorf("unknown open mode %q", cfg.Mode)
+}
+
+// editorCommand picks the editor command template: the configured template
+// wins, then $VISUAL, then $EDITOR (with the worktree path appended).
+func editorCommand(configured, visual, editor string) (string, error) {
+	if configured != "" {
+		return configured, nil
+	}
+	for _, e := range []string{visual, editor} {
+		if e != "" {
+			return e + ` "$WORKTREE_PATH"`, nil
+		}
+	}
+	return "", errors.New("no editor configured: set $VISUAL, $EDITOR or `git config wtw.editor`")
+}
+
+// terminalCommand picks the terminal command template for goos.
+func terminalCommand(configured, goos string) (string, error) {
+	if configured != "" {
+		return configured, nil
+	}
+	if goos == "darwin" {
+		return `open -a Terminal "$WORKTREE_PATH"`, nil
+	}
+	return "", errors.New("no terminal configured: set `git config wtw.terminal`, e.g. 'gnome-terminal --working-directory=\"$WORKTREE_PATH\"'")
+}
+
+// tmuxArgs returns the tmux arguments that open name at path: a new window in
+// the current session when already inside tmux, otherwise a session that is
+// created or attached to (-A).
+func tmuxArgs(inside bool, name, path string) []string {
+	if inside {
+		return []string{"new-window", "-n", name, "-c", path}
+	}
+	return []string{"new-session", "-A", "-s", name, "-c", path}
+}
+
+// tmuxName makes name safe for use as a tmux session or window target, where
+// "." and ":" are separators.
+func tmuxName(name string) string {
+	return strings.NewReplacer(".", "_", ":", "_").Replace(name)
+}
+
+// runTemplate runs a command template through sh in dir, attached to the
+// terminal.
+func runTemplate(tmpl, dir string, env []string) error {
+	cmd := exec.Command("sh", "-c", tmpl)
+	cmd.Dir = dir
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = env
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %w", tmpl, err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package worktree
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEditorCommand(t *testing.T) {
+	cases := []struct {
+		configured, visual, editor string
+		want                       string
+	}{
+		{`code "$WORKTREE_PATH"`, "vim", "nano", `code "$WORKTREE_PATH"`},
+		{"", "code -w", "nano", `code -w "$WORKTREE_PATH"`},
+		{"", "", "nano", `nano "$WORKTREE_PATH"`},
+	}
+	for _, tc := range cases {
+		got, err := editorCommand(tc.configured, tc.visual, tc.editor)
+		if err != nil || got != tc.want {
+			t.Errorf("editorCommand(%q, %q, %q) = %q, %v; want %q", tc.configured, tc.visual, tc.editor, got, err, tc.want)
+		}
+	}
+	if _, err := editorCommand("", "", ""); err == nil {
+		t.Error("expected error when no editor is available")
+	}
+}
+
+func TestTerminalCommand(t *testing.T) {
+	if got, _ := terminalCommand("kitty", "linux"); got != "kitty" {
+		t.Errorf("configured terminal = %q, want kitty", got)
+	}
+	if _, err := terminalCommand("", "darwin"); err != nil {
+		t.Errorf("darwin default: %v", err)
+	}
+	if _, err := terminalCommand("", "linux"); err == nil {
+		t.Error("expected error for linux without a configured terminal")
+	}
+}
+
+func TestTmuxArgs(t *testing.T) {
+	got := tmuxArgs(true, "app-x", "/p")
+	want := []string{"new-window", "-n", "app-x", "-c", "/p"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("inside tmux: %v, want %v", got, want)
+	}
+	got = tmuxArgs(false, "app-x", "/p")
+	want = []string{"new-session", "-A", "-s", "app-x", "-c", "/p"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("outside tmux: %v, want %v", got, want)
+	}
+	if got := tmuxName("app-v1.2:x"); got != "app-v1_2_x" {
+		t.Errorf("tmuxName = %q", got)
+	}
+}
+
+func TestRunTemplate_SeesScriptVars(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	env := append(os.Environ(), scriptEnv(dir, "feature-x", "/repo/myapp", dir)...)
+
+	if err := runTemplate(`echo "$BRANCH_NAME|$REPO_NAME" > out`, dir, env); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(data)); got != "feature-x|myapp" {
+		t.Errorf("template output = %q", got)
+	}
+}
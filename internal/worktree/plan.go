@@ -0,0 +1,341 @@
+package worktree
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"wtw/internal/git"
+	"wtw/internal/ports"
+	"wtw/internal/ui"
+)
+
+// Branch actions of a create plan.
+const (
+	branchNew      = "new"      // created from the current branch
+	branchExisting = "existing" // a local branch, checked out as is
+	branchTracking = "tracking" // created from a remote branch, tracking it
+)
+
+// createPlan is what Create resolved before changing anything on disk.
+// Create executes it; with DryRun it is only printed.
+type createPlan struct {
+	cfg        CreateConfig
+	branch     string
+	path       string
+	action     string // branchNew, branchExisting or branchTracking
+	base       string // branch a new branch starts from, recorded for `wtw sync`
+	remote     string // remote branch of the same name, which --track starts from
+	replaceDir bool   // an unregistered directory is in the way
+}
+
+// planCreate validates cfg and resolves the worktree path and branch action.
+// Apart from asking for a missing branch name it has no side effects.
+func planCreate(cfg CreateConfig) (*createPlan, error) {
+	branchName := cfg.BranchName
+	if branchName == "" {
+		branchName = ui.Ask("Branch name:")
+	}
+	if branchName == "" {
+		return nil, errors.New("branch name cannot be empty")
+	}
+	if strings.ContainsAny(branchName, " \t\n") {
+		return nil, errors.New("branch name cannot contain spaces")
+	}
+
+	worktreeDirname := cfg.RepoName + "-" + SanitizeBranch(branchName)
+
+	var worktreePath string
+	if cfg.BaseDir != "" {
+		abs, err := filepath.Abs(cfg.BaseDir)
+		if err != nil {
+			return nil, err
+		}
+		worktreePath = filepath.Join(abs, worktreeDirname)
+	} else {
+		worktreePath = filepath.Join(filepath.Dir(cfg.RepoRoot), worktreeDirname)
+	}
+	p := &createPlan{cfg: cfg, branch: branchName, path: worktreePath}
+
+	// Check for existing worktree at path
+	if _, err := os.Stat(worktreePath); err == nil {
+		if git.IsRegisteredWorktree(cfg.RepoRoot, worktreePath) {
+			return nil, fmt.Errorf("worktree already exists at: %s", worktreePath)
+		}
+		p.replaceDir = true
+	}
+
+	// Check if branch is already checked out elsewhere
+	if existing := git.WorktreeForBranch(cfg.RepoRoot, branchName); existing != "" {
+		return nil, fmt.Errorf("branch %q already checked out at: %s", branchName, existing)
+	}
+
+	switch {
+	case git.BranchExists(cfg.RepoRoot, branchName):
+		p.action = branchExisting
+	default:
+		p.remote = git.RemoteBranch(cfg.RepoRoot, branchName)
+		if cfg.Track && p.remote != "" {
+			p.action = branchTracking
+		} else {
+			p.action, p.base = branchNew, git.CurrentBranch(cfg.RepoRoot)
+		}
+	}
+	return p, nil
+}
+
+// gitArgs returns the `git worktree add` arguments for the plan. The
+// checkout is a separate step of the creation journal.
+func (p *createPlan) gitArgs() []string {
+	switch p.action {
+	case branchExisting:
+		return []string{"worktree", "add", "--no-checkout", p.path, p.branch}
+	case branchTracking:
+		return []string{"worktree", "add", "--no-checkout", "--track", "-b", p.branch, p.path, p.remote}
+	}
+	return []string{"worktree", "add", "--no-checkout", "-b", p.branch, p.path}
+}
+
+// setupSpec returns the setup run of the plan.
+func (p *createPlan) setupSpec() setupSpec {
+	return setupSpec{
+		script:       p.cfg.SetupScript,
+		steps:        p.cfg.Steps,
+		timeout:      p.cfg.SetupTimeout,
+		interpreter:  p.cfg.Interpreter,
+		inputs:       p.cfg.SetupInputs,
+		worktreePath: p.path,
+		branchName:   p.branch,
+		repoRoot:     p.cfg.RepoRoot,
+		originalDir:  p.cfg.OriginalDir,
+	}
+}
+
+// print describes the plan for a dry run.
+func (p *createPlan) print() error {
+	cfg := p.cfg
+	fmt.Println("Dry run: nothing will be changed.")
+	planLine("worktree", p.path)
+	switch p.action {
+	case branchNew:
+		from := p.base
+		if from == "" {
+			from = "HEAD"
+		}
+		note := ""
+		if p.remote != "" {
+			note = "; " + p.remote + " exists, --track would start from it"
+		}
+		planLine("branch", p.branch+" (new, from "+from+note+")")
+	case branchExisting:
+		planLine("branch", p.branch+" (existing)")
+	case branchTracking:
+		planLine("branch", p.branch+" (new, tracking "+p.remote+")")
+	}
+	if p.replaceDir {
+		planLine("delete", p.path+" (not a worktree; you would be asked first)")
+	}
+	planLine("git", gitCommand(cfg.RepoRoot, p.gitArgs()...))
+	if p.action == branchNew && p.base != "" {
+		planLine("git", gitCommand(cfg.RepoRoot, "config", "branch."+p.branch+".wtwBase", p.base))
+	}
+	planLine("git", gitCommand(p.path, "checkout", "-f"))
+
+	if cfg.Ports != nil {
+		planLine("ports", fmt.Sprintf("a block of %d from %d-%d", cfg.Ports.BlockSize, cfg.Ports.Min, cfg.Ports.Max))
+	}
+	if err := p.printIncludes(); err != nil {
+		return err
+	}
+	if cfg.Clone != nil {
+		for _, d := range cfg.Clone.Dirs {
+			if info, err := os.Stat(filepath.Join(cfg.RepoRoot, d.Dir)); err != nil || !info.IsDir() {
+				continue
+			}
+			planLine("clone", d.Dir+" if "+strings.Join(d.Lockfiles, ", ")+" match the main repo")
+		}
+	}
+	if cfg.SetupScript != "" {
+		if err := printSetupPlan(p.setupSpec(), cfg.Background); err != nil {
+			return err
+		}
+		if cfg.Ports != nil {
+			planLine("", "WTW_PORT, WTW_PORT_<n> and WORKTREE_INDEX once ports are allocated")
+		}
+	}
+	if cfg.Atomic {
+		planLine("atomic", "all of the above is undone if a step fails or is interrupted")
+	}
+	if cfg.Open != nil {
+		planLine("open", string(cfg.Open.Mode))
+	}
+	if cfg.Shell {
+		planLine("shell", "start a subshell in "+p.path)
+	}
+	return nil
+}
+
+// printIncludes lists the files .worktreeinclude would bring over.
+func (p *createPlan) printIncludes() error {
+	data, err := os.ReadFile(filepath.Join(p.cfg.RepoRoot, IncludeFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	rules, err := parseIncludeFile(string(data))
+	if err != nil {
+		return fmt.Errorf("%s: %w", IncludeFileName, err)
+	}
+	items, err := matchIncludes(rules, p.cfg.RepoRoot)
+	if err != nil {
+		return err
+	}
+	for _, it := range items {
+		planLine(it.mode, it.rel)
+	}
+	return nil
+}
+
+// printSetupPlan describes a setup run: the script or steps, how they would
+// be run and the variables they would get.
+func printSetupPlan(spec setupSpec, background bool) error {
+	how := ""
+	if background {
+		how = " in the background"
+	}
+	if spec.timeout > 0 {
+		how += ", stopped after " + spec.timeout.String()
+	}
+	if untrusted := untrustedScripts(spec.script, spec.repoRoot); len(untrusted) > 0 {
+		how += " (asks to approve " + strings.Join(untrusted, ", ") + " first)"
+	}
+
+	switch {
+	case IsStepsFile(spec.script):
+		steps, err := LoadSteps(spec.script)
+		if err != nil {
+			return err
+		}
+		if err := checkSelection(steps, spec.steps); err != nil {
+			return err
+		}
+		planLine("setup", spec.script+how)
+		for _, s := range steps {
+			if spec.steps.includes(s.Name) {
+				planLine("", "step "+s.Name)
+			}
+		}
+	case isSetupDir(spec.script):
+		scripts, err := setupDirScripts(spec.script)
+		if err != nil {
+			return err
+		}
+		planLine("setup", spec.script+how)
+		for _, s := range scripts {
+			argv, err := scriptCommand(s, "")
+			if err != nil {
+				return err
+			}
+			planLine("", strings.Join(argv, " "))
+		}
+	default:
+		argv, err := scriptCommand(spec.script, spec.interpreter)
+		if err != nil {
+			return err
+		}
+		planLine("setup", strings.Join(argv, " ")+how)
+	}
+	planLine("", "in "+spec.worktreePath+" with:")
+	for _, kv := range scriptEnv(spec.worktreePath, spec.branchName, spec.repoRoot, spec.originalDir) {
+		planLine("", "  "+kv)
+	}
+	return nil
+}
+
+// removePlan is what Remove would delete.
+type removePlan struct {
+	cfg     RemoveConfig
+	branch  string
+	files   int
+	bytes   int64
+	changes []string // `git status --porcelain` lines that would be lost
+	ports   *ports.Allocation
+	logs    string // setup logs directory, if any
+}
+
+// planRemove inspects the worktree Remove would delete.
+func planRemove(cfg RemoveConfig) (*removePlan, error) {
+	p := &removePlan{cfg: cfg, branch: git.CurrentBranch(cfg.WorktreeRoot)}
+	err := filepath.WalkDir(cfg.WorktreeRoot, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		p.files++
+		if info, err := d.Info(); err == nil {
+			p.bytes += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if out, err := git.OutputIn(cfg.WorktreeRoot, "status", "--porcelain"); err == nil && out != "" {
+		p.changes = strings.Split(out, "\n")
+	}
+	if a, ok := ports.Lookup(cfg.WorktreeRoot); ok {
+		p.ports = &a
+	}
+	if dir, err := setupDir(cfg.WorktreeRoot); err == nil && fileExists(dir) {
+		p.logs = dir
+	}
+	return p, nil
+}
+
+// print describes the plan for a dry run.
+func (p *removePlan) print() {
+	fmt.Println("Dry run: nothing will be changed.")
+	planLine("git", gitCommand(p.cfg.MainRepoRoot, "worktree", "remove", "--force", p.cfg.WorktreeRoot))
+	planLine("delete", fmt.Sprintf("%s (%d files, %s)", p.cfg.WorktreeRoot, p.files, formatBytes(p.bytes)))
+	if len(p.changes) > 0 {
+		planLine("lose", fmt.Sprintf("%d uncommitted change(s):", len(p.changes)))
+		for _, c := range p.changes {
+			planLine("", "  "+c)
+		}
+	}
+	if p.logs != "" {
+		planLine("delete", p.logs+" (setup logs)")
+	}
+	if p.ports != nil {
+		planLine("ports", fmt.Sprintf("free %d-%d", p.ports.Ports[0], p.ports.Ports[len(p.ports.Ports)-1]))
+	}
+	if p.branch != "" {
+		planLine("keep", "branch "+p.branch)
+	}
+}
+
+// planLine prints one line of a dry-run plan under a short label.
+func planLine(label, text string) {
+	fmt.Printf("  %-9s %s\n", label, text)
+}
+
+// gitCommand renders a git invocation for display.
+func gitCommand(dir string, args ...string) string {
+	parts := []string{"git", "-C", shellQuote(dir)}
+	for _, a := range args {
+		parts = append(parts, shellQuote(a))
+	}
+	return strings.Join(parts, " ")
+}
+
+// shellQuote quotes s for display in a shell command when it needs it.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:=@+,") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,138 @@
+package worktree
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"wtw/internal/git"
+)
+
+// captureStdout returns what fn printed to stdout.
+func captureStdout(t *testing.T, fn func() error) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := os.Stdout
+	os.Stdout = w
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- string(data)
+	}()
+	fnErr := fn()
+	os.Stdout = orig
+	_ = w.Close()
+	out := <-done
+	if fnErr != nil {
+		t.Fatalf("%v\n%s", fnErr, out)
+	}
+	return out
+}
+
+func TestPlanCreate_BranchActions(t *testing.T) {
+	repo := setupRepo(t)
+	gitIn(t, repo, "branch", "existing")
+	head := gitIn(t, repo, "rev-parse", "HEAD")
+	gitIn(t, repo, "update-ref", "refs/remotes/origin/remote-only", head)
+
+	cases := []struct {
+		branch, action string
+		track          bool
+		args           []string
+	}{
+		{"fresh", branchNew, false, []string{"worktree", "add", "--no-checkout", "-b", "fresh"}},
+		{"existing", branchExisting, false, []string{"worktree", "add", "--no-checkout"}},
+		{"remote-only", branchNew, false, []string{"worktree", "add", "--no-checkout", "-b", "remote-only"}},
+		{"remote-only", branchTracking, true, []string{"worktree", "add", "--no-checkout", "--track", "-b", "remote-only"}},
+	}
+	for _, c := range cases {
+		p, err := planCreate(CreateConfig{BranchName: c.branch, RepoRoot: repo, RepoName: "repo", Track: c.track})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.action != c.action {
+			t.Errorf("%s: action = %s, want %s", c.branch, p.action, c.action)
+		}
+		if got := strings.Join(p.gitArgs(), " "); !strings.HasPrefix(got, strings.Join(c.args, " ")) {
+			t.Errorf("%s: git args = %s", c.branch, got)
+		}
+	}
+}
+
+func TestCreate_TracksRemoteBranchOnlyWhenAsked(t *testing.T) {
+	repo := setupRepo(t)
+	gitIn(t, repo, "remote", "add", "origin", repo)
+	head := gitIn(t, repo, "rev-parse", "HEAD")
+	gitIn(t, repo, "update-ref", "refs/remotes/origin/remote-only", head)
+	gitIn(t, repo, "update-ref", "refs/remotes/origin/tracked", head)
+
+	wt := createWorktree(t, repo, "remote-only")
+	if got := git.Upstream(wt, "remote-only"); got != "" {
+		t.Errorf("upstream without --track = %q, want none", got)
+	}
+
+	cfg := CreateConfig{BranchName: "tracked", RepoRoot: repo, RepoName: filepath.Base(repo), OriginalDir: repo, Track: true}
+	if err := Create(cfg); err != nil {
+		t.Fatal(err)
+	}
+	wt = filepath.Join(filepath.Dir(repo), filepath.Base(repo)+"-tracked")
+	t.Cleanup(func() { _ = os.RemoveAll(wt) })
+	if got := git.Upstream(wt, "tracked"); got != "origin/tracked" {
+		t.Errorf("upstream with --track = %q, want origin/tracked", got)
+	}
+}
+
+func TestCreate_DryRunChangesNothing(t *testing.T) {
+	repo := setupRepo(t)
+	writeTree(t, repo, map[string]string{
+		".env":              "A=1",
+		".worktreeinclude":  ".env\n",
+		".git/info/exclude": ".env\n.worktreeinclude\n",
+	})
+	script := writeScript(t, "touch ran\n")
+	cfg := CreateConfig{
+		BranchName:  "dry",
+		RepoRoot:    repo,
+		RepoName:    filepath.Base(repo),
+		OriginalDir: repo,
+		SetupScript: script,
+		DryRun:      true,
+	}
+	out := captureStdout(t, func() error { return Create(cfg) })
+
+	path := filepath.Join(filepath.Dir(repo), filepath.Base(repo)+"-dry")
+	for _, want := range []string{path, "dry (new, from", "worktree add --no-checkout -b dry", "checkout -f", "copy      .env", "bash " + script, "BRANCH_NAME=dry"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("plan lacks %q:\n%s", want, out)
+		}
+	}
+	if _, err := os.Stat(path); err == nil {
+		t.Error("dry run created the worktree")
+	}
+	if git.BranchExists(repo, "dry") {
+		t.Error("dry run created the branch")
+	}
+}
+
+func TestRemove_DryRun(t *testing.T) {
+	repo := setupRepo(t)
+	wt := createWorktree(t, repo, "doomed")
+	writeTree(t, wt, map[string]string{"scratch.txt": "wip"})
+
+	out := captureStdout(t, func() error {
+		return Remove(RemoveConfig{WorktreeRoot: wt, MainRepoRoot: repo, DryRun: true})
+	})
+	for _, want := range []string{"worktree remove --force " + wt, "1 uncommitted change(s)", "?? scratch.txt", "keep      branch doomed"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("plan lacks %q:\n%s", want, out)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(wt, "scratch.txt")); err != nil {
+		t.Error("dry run removed the worktree")
+	}
+}
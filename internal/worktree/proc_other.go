@@ -0,0 +1,31 @@
+//go:build !unix
+
+package worktree
+
+import (
+	"os"
+	"syscall"
+)
+
+func detachedProcAttr() *syscall.SysProcAttr { return nil }
+
+// processAlive reports whether a process with this pid exists. On Windows,
+// FindProcess fails for pids that are gone.
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	_ = p.Release()
+	return true
+}
+
+// Process groups are only managed on Unix; elsewhere a stopped setup script
+// is killed on its own.
+func setupProcAttr(_ any) (*syscall.SysProcAttr, bool) { return nil, false }
+
+func reclaimTerminal() {}
+
+func signalGroup(p *os.Process, _ os.Signal) error { return p.Kill() }
+
+func groupAlive(_ *os.Process) bool { return false }
@@ -0,0 +1,71 @@
+//go:build unix
+
+package worktree
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+	"unsafe"
+)
+
+// detachedProcAttr starts a process in its own session, so it outlives the
+// terminal and is not hit by the terminal's Ctrl-C.
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
+
+// processAlive reports whether a process with this pid exists.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// setupProcAttr puts a setup script in its own process group, so signals
+// reach everything it started. When wtw owns the terminal, the group is made
+// the terminal's foreground group so the script can still read from it;
+// foreground reports whether that happened.
+func setupProcAttr(stdin any) (attr *syscall.SysProcAttr, foreground bool) {
+	f, ok := stdin.(*os.File)
+	if ok {
+		pgrp, err := tcgetpgrp(int(f.Fd()))
+		foreground = err == nil && pgrp == syscall.Getpgrp()
+	}
+	if foreground {
+		return &syscall.SysProcAttr{Foreground: true, Ctty: int(f.Fd())}, true
+	}
+	return &syscall.SysProcAttr{Setpgid: true}, false
+}
+
+// reclaimTerminal makes wtw's process group the foreground group of stdin
+// again after a foreground script exits.
+func reclaimTerminal() {
+	// A background process that changes the foreground group gets SIGTTOU.
+	signal.Ignore(syscall.SIGTTOU)
+	defer signal.Reset(syscall.SIGTTOU)
+	pgrp := int32(syscall.Getpgrp())
+	_, _, _ = syscall.Syscall(syscall.SYS_IOCTL, os.Stdin.Fd(), uintptr(syscall.TIOCSPGRP), uintptr(unsafe.Pointer(&pgrp)))
+}
+
+func tcgetpgrp(fd int) (int, error) {
+	var pgrp int32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), uintptr(syscall.TIOCGPGRP), uintptr(unsafe.Pointer(&pgrp))); errno != 0 {
+		return 0, errno
+	}
+	return int(pgrp), nil
+}
+
+// signalGroup sends sig to the process group led by p.
+func signalGroup(p *os.Process, sig os.Signal) error {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		s = syscall.SIGTERM
+	}
+	return syscall.Kill(-p.Pid, s)
+}
+
+// groupAlive reports whether any process is left in the group led by p.
+func groupAlive(p *os.Process) bool {
+	return syscall.Kill(-p.Pid, 0) == nil
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package worktree
+
+import "os"
+
+// reflinkFile is only implemented on Linux; elsewhere cloning falls back to
+// hard links or plain copies.
+func reflinkFile(_, _ string, _ os.FileMode) error {
+	return errReflinkUnsupported
+}
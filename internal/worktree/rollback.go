@@ -0,0 +1,136 @@
+package worktree
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"wtw/internal/ui"
+)
+
+// errCreateInterrupted is returned when Ctrl-C arrives between the steps of
+// an atomic create.
+var errCreateInterrupted = errors.New("interrupted")
+
+// undoStep is one completed step of Create and how to take it back.
+type undoStep struct {
+	desc string // past tense, e.g. "removed worktree /x"
+	undo func() error
+}
+
+// rollback records the steps Create took so that an atomic create can undo
+// them, newest first, when it fails or is interrupted.
+//
+// While armed it catches SIGINT and SIGTERM. Create holds mu while it works
+// and releases it while waiting for the user; a signal that arrives then
+// rolls back at once and exits, one that arrives during a step is acted on
+// when the step returns.
+type rollback struct {
+	mu          sync.Mutex
+	steps       []undoStep
+	settled     bool // committed or rolled back
+	interrupted atomic.Bool
+	sigs        chan os.Signal
+}
+
+// newRollback returns a rollback, armed against signals for an atomic
+// create. The caller holds its lock until it commits or fails.
+func newRollback(armed bool) *rollback {
+	r := &rollback{}
+	r.mu.Lock()
+	if !armed {
+		r.settled = true
+		return r
+	}
+	r.sigs = make(chan os.Signal, 1)
+	signal.Notify(r.sigs, os.Interrupt, syscall.SIGTERM)
+	go r.watch()
+	return r
+}
+
+// watch rolls back on the first signal once Create is not mid-step.
+func (r *rollback) watch() {
+	if _, ok := <-r.sigs; !ok {
+		return
+	}
+	r.interrupted.Store(true)
+	r.mu.Lock()
+	if r.settled {
+		r.mu.Unlock()
+		return
+	}
+	fmt.Println()
+	r.undoAll()
+	r.mu.Unlock()
+	ui.Error("interrupted; nothing was kept")
+	os.Exit(130)
+}
+
+// add records a completed step. desc says what undoing it does.
+func (r *rollback) add(desc string, undo func() error) {
+	r.steps = append(r.steps, undoStep{desc: desc, undo: undo})
+}
+
+// check reports an interrupt that arrived during the last step.
+func (r *rollback) check() error {
+	if r.interrupted.Load() {
+		return errCreateInterrupted
+	}
+	return nil
+}
+
+// unlocked runs fn, typically a prompt, with the lock released so that
+// Ctrl-C rolls back straight away.
+func (r *rollback) unlocked(fn func()) {
+	r.mu.Unlock()
+	defer r.mu.Lock()
+	fn()
+}
+
+// fail undoes the recorded steps of an atomic create and returns err;
+// otherwise it only returns err.
+func (r *rollback) fail(err error) error {
+	if r.settled {
+		return err
+	}
+	r.undoAll()
+	r.settle()
+	return err
+}
+
+// commit keeps everything: the create succeeded, or isn't atomic.
+func (r *rollback) commit() {
+	if !r.settled {
+		r.settle()
+	}
+}
+
+func (r *rollback) settle() {
+	r.settled = true
+	if r.sigs != nil {
+		signal.Stop(r.sigs)
+		close(r.sigs)
+	}
+	r.mu.Unlock()
+}
+
+// undoAll takes back every step, newest first, and prints what was undone.
+func (r *rollback) undoAll() {
+	if len(r.steps) == 0 {
+		return
+	}
+	fmt.Println("Rolling back:")
+	for i := len(r.steps) - 1; i >= 0; i-- {
+		s := r.steps[i]
+		if err := s.undo(); err != nil {
+			ui.Error("could not undo (" + s.desc + "): " + err.Error())
+			continue
+		}
+		fmt.Println("  " + s.desc)
+	}
+	r.steps = nil
+}
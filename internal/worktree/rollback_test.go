@@ -0,0 +1,103 @@
+package worktree
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"wtw/internal/git"
+	"wtw/internal/ports"
+)
+
+func TestRollback_UndoesNewestFirst(t *testing.T) {
+	var undone []string
+	r := newRollback(true)
+	for _, name := range []string{"branch", "worktree", "ports"} {
+		name := name
+		r.add("undid "+name, func() error {
+			undone = append(undone, name)
+			return nil
+		})
+	}
+	want := errors.New("boom")
+	if err := r.fail(want); err != want {
+		t.Fatalf("fail returned %v", err)
+	}
+	if got := strings.Join(undone, ","); got != "ports,worktree,branch" {
+		t.Errorf("undo order = %s", got)
+	}
+	r.commit() // a no-op once rolled back
+}
+
+// atomicCreate creates a worktree whose setup runs script, with .env brought
+// over and ports allocated.
+func atomicCreate(t *testing.T, atomic bool, script string) (repo, path string, err error) {
+	t.Helper()
+	repo = setupRepo(t)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(repo, ".config"))
+	writeTree(t, repo, map[string]string{
+		".env":              "A=1",
+		".worktreeinclude":  ".env\n",
+		".git/info/exclude": ".env\n.worktreeinclude\n.config\n",
+	})
+	path = filepath.Join(filepath.Dir(repo), filepath.Base(repo)+"-atomic")
+	t.Cleanup(func() { _ = os.RemoveAll(path) })
+	err = Create(CreateConfig{
+		BranchName:  "atomic",
+		RepoRoot:    repo,
+		RepoName:    filepath.Base(repo),
+		OriginalDir: repo,
+		SetupScript: writeScript(t, script),
+		Ports:       &ports.Config{Min: 41000, Max: 41999, BlockSize: 2},
+		Atomic:      atomic,
+	})
+	return repo, path, err
+}
+
+func assertRolledBack(t *testing.T, repo, path string) {
+	t.Helper()
+	if _, err := os.Stat(path); err == nil {
+		t.Error("worktree directory still exists")
+	}
+	if git.IsRegisteredWorktree(repo, path) {
+		t.Error("worktree still registered")
+	}
+	if git.BranchExists(repo, "atomic") {
+		t.Error("branch still exists")
+	}
+	if _, ok := ports.Lookup(path); ok {
+		t.Error("ports still allocated")
+	}
+}
+
+func TestCreate_AtomicRollsBackFailedSetup(t *testing.T) {
+	repo, path, err := atomicCreate(t, true, "test -f .env && exit 3\n")
+	if err == nil || !strings.Contains(err.Error(), "setup failed") {
+		t.Fatalf("err = %v, want a setup failure", err)
+	}
+	assertRolledBack(t, repo, path)
+}
+
+func TestCreate_AtomicRollsBackInterruptedSetup(t *testing.T) {
+	repo, path, err := atomicCreate(t, true, "kill -INT $$\n")
+	if !errors.Is(err, ErrSetupInterrupted) {
+		t.Fatalf("err = %v, want ErrSetupInterrupted", err)
+	}
+	assertRolledBack(t, repo, path)
+}
+
+func TestCreate_NotAtomicKeepsFailedWorktree(t *testing.T) {
+	repo, path, err := atomicCreate(t, false, "exit 3\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !git.IsRegisteredWorktree(repo, path) || !git.BranchExists(repo, "atomic") {
+		t.Error("worktree or branch was removed")
+	}
+	if _, ok := ports.Lookup(path); !ok {
+		t.Error("ports were freed")
+	}
+	_ = ports.Free(path)
+}
@@ -0,0 +1,359 @@
+package worktree
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"wtw/internal/filelock"
+	"wtw/internal/git"
+)
+
+// Setup states recorded for each setup run.
+const (
+	SetupRunning   = "running"
+	SetupSucceeded = "succeeded"
+	SetupFailed    = "failed"
+	SetupTimedOut  = "timed out"
+	SetupStopped   = "interrupted"
+)
+
+// setupRunsKept is how many setup runs are kept per worktree; older logs
+// are deleted when a new run starts.
+const setupRunsKept = 10
+
+// setupIDFormat names runs so that they sort by start time.
+const setupIDFormat = "2006-01-02T15-04-05.000000"
+
+// SetupStatus is the record of one setup run. Each run has a
+// setup-<timestamp>.log and a matching .json in the worktree's git dir
+// (.git/worktrees/<name>/wtw), so they never show up in `git status` and go
+// away with the worktree.
+type SetupStatus struct {
+	ID          string    `json:"id"`
+	State       string    `json:"state"`
+	Script      string    `json:"script"`
+	ScriptHash  string    `json:"script_hash,omitempty"`
+	Fingerprint string    `json:"fingerprint,omitempty"` // script and inputs; see setupFingerprint
+	Background  bool      `json:"background,omitempty"`
+	PID         int       `json:"pid,omitempty"`
+	Started     time.Time `json:"started"`
+	Finished    time.Time `json:"finished"`
+	Duration    float64   `json:"duration_seconds"`
+	ExitCode    int       `json:"exit_code"`
+	Error       string    `json:"error,omitempty"`
+	EnvVars     []string  `json:"env_vars,omitempty"` // names only; values may be secrets
+	Log         string    `json:"log"`
+}
+
+// done reports whether the run has finished, one way or the other.
+func (s SetupStatus) done() bool { return s.State != SetupRunning }
+
+// failed reports whether the run finished without succeeding.
+func (s SetupStatus) failed() bool { return s.done() && s.State != SetupSucceeded }
+
+// setupDir returns the directory holding a worktree's setup records.
+func setupDir(worktreePath string) (string, error) {
+	gitDir, err := git.GitDir(worktreePath)
+	if err != nil {
+		return "", fmt.Errorf("not a git worktree: %s", worktreePath)
+	}
+	return filepath.Join(gitDir, "wtw"), nil
+}
+
+// setupRun is a setup run in progress. Output written to stdout and stderr
+// reaches wtw's own stdout and stderr and, unless those are a terminal, is
+// teed into the run's log.
+type setupRun struct {
+	path     string // record path without extension
+	status   SetupStatus
+	log      *os.File
+	stdout   io.Writer
+	stderr   io.Writer
+	deadline time.Time // zero means no timeout
+}
+
+// beginSetupRun records the start of a setup run in worktreePath. Outside a
+// git worktree nothing is recorded and output only goes to the terminal.
+func beginSetupRun(worktreePath, script string, background bool) (*setupRun, error) {
+	r := &setupRun{stdout: os.Stdout, stderr: os.Stderr}
+	dir, err := setupDir(worktreePath)
+	if err != nil {
+		return r, nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	rotateSetupRuns(dir, setupRunsKept-1)
+
+	now := time.Now()
+	id := now.Format(setupIDFormat)
+	for fileExists(filepath.Join(dir, "setup-"+id+".json")) {
+		now = now.Add(time.Microsecond)
+		id = now.Format(setupIDFormat)
+	}
+	r.path = filepath.Join(dir, "setup-"+id)
+	r.status = SetupStatus{
+		ID:         id,
+		State:      SetupRunning,
+		Script:     script,
+		Background: background,
+		Started:    now,
+		Log:        r.path + ".log",
+	}
+	if !background {
+		r.status.PID = os.Getpid()
+	}
+	if hash, err := fileHash(script); err == nil {
+		r.status.ScriptHash = hash
+	}
+	// The log is private: script output can include secrets.
+	if r.log, err = os.OpenFile(r.status.Log, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600); err != nil {
+		return nil, err
+	}
+	// A script writing to a terminal keeps it, for colours and [ -t 1 ]
+	// checks; teeing would hand it a pipe instead.
+	if isTerminal(os.Stdout) {
+		r.logf("wtw: output went to the terminal and is not logged")
+	} else {
+		r.stdout = io.MultiWriter(os.Stdout, r.log)
+		r.stderr = io.MultiWriter(os.Stderr, r.log)
+	}
+	return r, r.save()
+}
+
+// isTerminal reports whether f is a terminal.
+func isTerminal(f *os.File) bool {
+	st, err := f.Stat()
+	return err == nil && st.Mode()&os.ModeCharDevice != 0
+}
+
+// resumeSetupRun picks up a run begun by another process, as `wtw
+// setup-worker` does. Its stdout already is the log, so nothing is teed.
+func resumeSetupRun(worktreePath, id string) (*setupRun, error) {
+	dir, err := setupDir(worktreePath)
+	if err != nil {
+		return nil, err
+	}
+	r := &setupRun{path: filepath.Join(dir, "setup-"+id), stdout: os.Stdout, stderr: os.Stderr}
+	data, err := os.ReadFile(r.path + ".json")
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &r.status); err != nil {
+		return nil, err
+	}
+	r.status.PID = os.Getpid()
+	return r, r.save()
+}
+
+// logf writes a line to the log only.
+func (r *setupRun) logf(format string, args ...any) {
+	if r.log != nil {
+		fmt.Fprintf(r.log, format+"\n", args...)
+	}
+}
+
+// finish records the outcome of the run. env holds the variables the
+// script saw, of which only the names are kept.
+func (r *setupRun) finish(runErr error, env []string) error {
+	if r.log != nil {
+		defer func() { _ = r.log.Close() }()
+	}
+	if r.path == "" {
+		return nil
+	}
+	st := &r.status
+	st.PID = 0
+	st.Finished = time.Now()
+	st.Duration = st.Finished.Sub(st.Started).Seconds()
+	st.State = SetupSucceeded
+	st.EnvVars = nil
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		st.EnvVars = append(st.EnvVars, name)
+	}
+	if runErr != nil {
+		st.State, st.Error, st.ExitCode = SetupFailed, runErr.Error(), 1
+		var exitErr *exec.ExitError
+		switch {
+		case errors.Is(runErr, ErrSetupTimedOut):
+			st.State, st.ExitCode = SetupTimedOut, -1
+		case errors.Is(runErr, ErrSetupInterrupted):
+			st.State, st.ExitCode = SetupStopped, -1
+		case errors.As(runErr, &exitErr):
+			st.ExitCode = exitErr.ExitCode()
+		}
+	}
+	return r.save()
+}
+
+// fail records a run that could not get started.
+func (r *setupRun) fail(err error) {
+	_ = r.finish(err, nil)
+}
+
+// save writes the run's record. Writes hold the record's lock, as the
+// process that starts a background run and its worker both write it.
+func (r *setupRun) save() error {
+	if r.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(r.status, "", "  ")
+	if err != nil {
+		return err
+	}
+	unlock, err := filelock.Lock(r.path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = unlock() }()
+	return filelock.WriteFile(r.path+".json", data, 0o600)
+}
+
+// recordWorker records pid as the process of a background run unless the
+// worker has already taken the run over or finished it, so a worker that
+// dies before taking it over is not reported as running forever.
+func (r *setupRun) recordWorker(pid int) error {
+	unlock, err := filelock.Lock(r.path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = unlock() }()
+	data, err := os.ReadFile(r.path + ".json")
+	if err != nil {
+		return err
+	}
+	var st SetupStatus
+	if err := json.Unmarshal(data, &st); err != nil {
+		return err
+	}
+	if st.State != SetupRunning || st.PID != 0 {
+		return nil
+	}
+	st.PID = pid
+	if data, err = json.MarshalIndent(st, "", "  "); err != nil {
+		return err
+	}
+	return filelock.WriteFile(r.path+".json", data, 0o600)
+}
+
+// SetupRuns returns the recorded setup runs of a worktree, oldest first. A
+// run whose process died without recording a result is reported as failed.
+func SetupRuns(worktreePath string) ([]SetupStatus, error) {
+	dir, err := setupDir(worktreePath)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, "setup-*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	runs := make([]SetupStatus, 0, len(matches))
+	for _, m := range matches {
+		data, err := os.ReadFile(m)
+		if err != nil {
+			return nil, err
+		}
+		var st SetupStatus
+		if err := json.Unmarshal(data, &st); err != nil {
+			return nil, fmt.Errorf("corrupt setup record %s: %w", m, err)
+		}
+		if st.State == SetupRunning && st.PID != 0 && !processAlive(st.PID) {
+			st.State = SetupFailed
+			st.Error = "setup process exited without recording a result"
+		}
+		runs = append(runs, st)
+	}
+	return runs, nil
+}
+
+// ReadSetupStatus returns the latest setup run of a worktree. ok is false
+// when none was recorded.
+func ReadSetupStatus(worktreePath string) (st SetupStatus, ok bool, err error) {
+	runs, err := SetupRuns(worktreePath)
+	if err != nil || len(runs) == 0 {
+		return st, false, err
+	}
+	return runs[len(runs)-1], true, nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// rotateSetupRuns deletes all but the newest keep runs in dir.
+func rotateSetupRuns(dir string, keep int) {
+	matches, _ := filepath.Glob(filepath.Join(dir, "setup-*.json"))
+	sort.Strings(matches)
+	for len(matches) > keep {
+		base := strings.TrimSuffix(matches[0], ".json")
+		_ = os.Remove(base + ".json")
+		_ = os.Remove(base + ".log")
+		_ = os.Remove(base + ".lock")
+		matches = matches[1:]
+	}
+}
+
+// LogsConfig holds inputs for Logs.
+type LogsConfig struct {
+	WorktreePath string
+	Follow       bool // keep printing output until the run finishes
+	List         bool // list the recorded runs instead
+}
+
+// Logs prints the log of the worktree's latest setup run, or lists all
+// recorded runs.
+func Logs(cfg LogsConfig) error {
+	runs, err := SetupRuns(cfg.WorktreePath)
+	if err != nil {
+		return err
+	}
+	if len(runs) == 0 {
+		return errors.New("no setup has been recorded in " + cfg.WorktreePath)
+	}
+	if cfg.List {
+		for _, r := range runs {
+			fmt.Printf("%s  %-11s  exit %-3d  %6.1fs  %s\n",
+				r.Started.Local().Format("2006-01-02 15:04:05"), r.State, r.ExitCode, r.Duration, r.Log)
+		}
+		return nil
+	}
+
+	latest := runs[len(runs)-1]
+	f, err := os.Open(latest.Log)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	if _, err := io.Copy(os.Stdout, f); err != nil {
+		return err
+	}
+	if !cfg.Follow {
+		return nil
+	}
+	for {
+		st, _, err := ReadSetupStatus(cfg.WorktreePath)
+		if err != nil {
+			return err
+		}
+		// Copy whatever arrived, then stop once the run has finished: its
+		// log is complete by the time the record says so.
+		if _, err := io.Copy(os.Stdout, f); err != nil {
+			return err
+		}
+		if st.ID != latest.ID || st.done() {
+			return nil
+		}
+		time.Sleep(waitInterval)
+	}
+}
@@ -0,0 +1,103 @@
+package worktree
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunScript_RecordsRun(t *testing.T) {
+	repo := setupRepo(t)
+	wt := createWorktree(t, repo, "logged")
+
+	if err := RunScript(writeScript(t, "echo out\necho err >&2\n"), wt, "logged", repo, repo); err != nil {
+		t.Fatal(err)
+	}
+	if err := RunScript(writeScript(t, "echo partial\nexit 7\n"), wt, "logged", repo, repo); err == nil {
+		t.Fatal("expected the failing script to return an error")
+	}
+
+	runs, err := SetupRuns(wt)
+	if err != nil || len(runs) != 2 {
+		t.Fatalf("runs = %+v, err = %v", runs, err)
+	}
+	ok, failed := runs[0], runs[1]
+	if ok.State != SetupSucceeded || ok.ExitCode != 0 || ok.ScriptHash == "" {
+		t.Errorf("first run = %+v", ok)
+	}
+	if failed.State != SetupFailed || failed.ExitCode != 7 {
+		t.Errorf("second run = %+v", failed)
+	}
+	if !strings.Contains(strings.Join(ok.EnvVars, ","), "WORKTREE_PATH") {
+		t.Errorf("env var names = %v", ok.EnvVars)
+	}
+
+	data, err := os.ReadFile(ok.Log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); !strings.Contains(got, "out\n") || !strings.Contains(got, "err\n") {
+		t.Errorf("log = %q", got)
+	}
+	if filepath.Dir(ok.Log) != filepath.Join(gitIn(t, wt, "rev-parse", "--absolute-git-dir"), "wtw") {
+		t.Errorf("log stored at %s", ok.Log)
+	}
+	for _, f := range []string{ok.Log, strings.TrimSuffix(ok.Log, ".log") + ".json"} {
+		if info, err := os.Stat(f); err != nil || info.Mode().Perm() != 0o600 {
+			t.Errorf("%s: mode %v, %v; want 0600", f, info.Mode().Perm(), err)
+		}
+	}
+}
+
+func TestRunScript_DoesNotWaitForBackgroundedChildren(t *testing.T) {
+	repo := setupRepo(t)
+	wt := createWorktree(t, repo, "bg-child")
+
+	start := time.Now()
+	if err := RunScript(writeScript(t, "sleep 5 &\necho started\n"), wt, "bg-child", repo, repo); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d > 3*time.Second {
+		t.Errorf("setup took %v; it waited for the backgrounded sleep", d)
+	}
+	st, _, _ := ReadSetupStatus(wt)
+	if st.State != SetupSucceeded {
+		t.Errorf("state = %q, want succeeded", st.State)
+	}
+	if data, _ := os.ReadFile(st.Log); !strings.Contains(string(data), "started\n") {
+		t.Errorf("log = %q", data)
+	}
+}
+
+func TestSetupRuns_Rotation(t *testing.T) {
+	repo := setupRepo(t)
+	script := writeScript(t, "true\n")
+	for i := 0; i < setupRunsKept+3; i++ {
+		if err := RunScript(script, repo, "main", repo, repo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runs, err := SetupRuns(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != setupRunsKept {
+		t.Errorf("kept %d runs, want %d", len(runs), setupRunsKept)
+	}
+	logs, _ := filepath.Glob(filepath.Join(filepath.Dir(runs[0].Log), "setup-*.log"))
+	if len(logs) != setupRunsKept {
+		t.Errorf("kept %d logs, want %d", len(logs), setupRunsKept)
+	}
+}
+
+func TestRunScript_OutsideGitRecordsNothing(t *testing.T) {
+	dir := t.TempDir()
+	if err := RunScript(writeScript(t, "true\n"), dir, "x", dir, dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SetupRuns(dir); err == nil {
+		t.Error("expected SetupRuns to fail outside a git worktree")
+	}
+}
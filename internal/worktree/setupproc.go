@@ -0,0 +1,118 @@
+package worktree
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Errors that tell a setup that was stopped apart from one that failed.
+var (
+	ErrSetupTimedOut    = errors.New("setup timed out")
+	ErrSetupInterrupted = errors.New("setup interrupted")
+)
+
+// killGrace is how long a setup script gets to exit after SIGINT or SIGTERM
+// before its whole process group is killed.
+var killGrace = 5 * time.Second
+
+// outputGrace is how long output from a finished setup script's leftover
+// background processes (a dev server started with &) is still copied when
+// its output is teed, before wtw stops waiting for them.
+const outputGrace = 500 * time.Millisecond
+
+// runSetupCommand runs a setup script in its own process group. Until the
+// deadline (if any), SIGINT and SIGTERM received by wtw are forwarded to the
+// whole group; at the deadline the group gets SIGTERM. Either way the group
+// has killGrace to exit before it is killed, and a second signal kills it
+// straight away.
+func runSetupCommand(cmd *exec.Cmd, deadline time.Time) error {
+	if !deadline.IsZero() && !time.Now().Before(deadline) {
+		return ErrSetupTimedOut
+	}
+
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	if cmd.WaitDelay == 0 {
+		cmd.WaitDelay = outputGrace
+	}
+	foreground := false
+	cmd.SysProcAttr, foreground = setupProcAttr(cmd.Stdin)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	if foreground {
+		defer reclaimTerminal()
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		t := time.NewTimer(time.Until(deadline))
+		defer t.Stop()
+		timeout = t.C
+	}
+
+	var stopped error
+	select {
+	case err := <-done:
+		if errors.Is(err, exec.ErrWaitDelay) {
+			return nil // the script succeeded; something it started kept the output open
+		}
+		if interruptedBy(err) {
+			// In the foreground the terminal's Ctrl-C reaches the script
+			// directly rather than through wtw.
+			return ErrSetupInterrupted
+		}
+		return err
+	case sig := <-sigs:
+		stopped = ErrSetupInterrupted
+		_ = signalGroup(cmd.Process, sig)
+	case <-timeout:
+		stopped = ErrSetupTimedOut
+		_ = signalGroup(cmd.Process, syscall.SIGTERM)
+	}
+
+	// Wait for the script and anything it left behind in its group.
+	grace := time.NewTimer(killGrace)
+	defer grace.Stop()
+	poll := time.NewTicker(50 * time.Millisecond)
+	defer poll.Stop()
+	exited := false
+wait:
+	for !exited || groupAlive(cmd.Process) {
+		select {
+		case <-done:
+			exited = true
+		case <-poll.C:
+		case <-sigs:
+			break wait
+		case <-grace.C:
+			break wait
+		}
+	}
+	_ = signalGroup(cmd.Process, syscall.SIGKILL)
+	if !exited {
+		<-done
+	}
+	return stopped
+}
+
+// interruptedBy reports whether a script exited because of Ctrl-C: killed by
+// SIGINT, or bash's conventional exit status 130.
+func interruptedBy(err error) bool {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return false
+	}
+	if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() && ws.Signal() == syscall.SIGINT {
+		return true
+	}
+	return exitErr.ExitCode() == 130
+}
@@ -0,0 +1,132 @@
+//go:build unix
+
+package worktree
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func shortGrace(t *testing.T) {
+	t.Helper()
+	orig := killGrace
+	killGrace = 300 * time.Millisecond
+	t.Cleanup(func() { killGrace = orig })
+}
+
+// waitForFile polls until path has content, returning it.
+func waitForFile(t *testing.T, path string) string {
+	t.Helper()
+	for i := 0; i < 200; i++ {
+		if data, err := os.ReadFile(path); err == nil && len(data) > 0 {
+			return strings.TrimSpace(string(data))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("%s never appeared", path)
+	return ""
+}
+
+// running reports whether pid exists and is not a zombie waiting for a
+// reaper that may never come in a container.
+func running(pid int) bool {
+	if syscall.Kill(pid, 0) != nil {
+		return false
+	}
+	out, err := exec.Command("ps", "-o", "stat=", "-p", strconv.Itoa(pid)).Output()
+	return err == nil && !strings.HasPrefix(strings.TrimSpace(string(out)), "Z")
+}
+
+func TestRunSetupCommand_TimeoutKillsProcessGroup(t *testing.T) {
+	shortGrace(t)
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	// The grandchild ignores SIGTERM, so only the forced kill stops it.
+	cmd := exec.Command("bash", "-c", `(trap '' TERM; sleep 30) & echo $! > `+pidFile+`; wait`)
+
+	start := time.Now()
+	err := runSetupCommand(cmd, time.Now().Add(200*time.Millisecond))
+	if !errors.Is(err, ErrSetupTimedOut) {
+		t.Fatalf("err = %v, want ErrSetupTimedOut", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("took %s", d)
+	}
+	pid, _ := strconv.Atoi(waitForFile(t, pidFile))
+	time.Sleep(50 * time.Millisecond)
+	if running(pid) {
+		_ = syscall.Kill(pid, syscall.SIGKILL)
+		t.Error("grandchild survived the timeout")
+	}
+}
+
+func TestRunSetupCommand_GracefulExitOnTimeout(t *testing.T) {
+	orig := killGrace
+	killGrace = 10 * time.Second
+	t.Cleanup(func() { killGrace = orig })
+
+	cmd := exec.Command("bash", "-c", `trap 'exit 0' TERM; while true; do sleep 0.05; done`)
+	start := time.Now()
+	err := runSetupCommand(cmd, time.Now().Add(200*time.Millisecond))
+	if !errors.Is(err, ErrSetupTimedOut) {
+		t.Fatalf("err = %v, want ErrSetupTimedOut", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("script that exits on SIGTERM took %s to stop", d)
+	}
+}
+
+func TestRunSetupCommand_ForwardsSignals(t *testing.T) {
+	shortGrace(t)
+	ready := filepath.Join(t.TempDir(), "ready")
+	cmd := exec.Command("bash", "-c", `echo up > `+ready+`; sleep 30`)
+
+	go func() {
+		waitForFile(t, ready)
+		_ = syscall.Kill(os.Getpid(), syscall.SIGTERM)
+	}()
+	if err := runSetupCommand(cmd, time.Time{}); !errors.Is(err, ErrSetupInterrupted) {
+		t.Fatalf("err = %v, want ErrSetupInterrupted", err)
+	}
+}
+
+func TestRunSetupCommand_DistinguishesOutcomes(t *testing.T) {
+	err := runSetupCommand(exec.Command("bash", "-c", "exit 3"), time.Time{})
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Errorf("failed script: err = %v", err)
+	}
+	if err := runSetupCommand(exec.Command("bash", "-c", "exit 130"), time.Time{}); !errors.Is(err, ErrSetupInterrupted) {
+		t.Errorf("exit 130: err = %v, want ErrSetupInterrupted", err)
+	}
+	if err := runSetupCommand(exec.Command("true"), time.Now().Add(-time.Second)); !errors.Is(err, ErrSetupTimedOut) {
+		t.Errorf("past deadline: err = %v, want ErrSetupTimedOut", err)
+	}
+	if err := runSetupCommand(exec.Command("true"), time.Time{}); err != nil {
+		t.Errorf("ok script: err = %v", err)
+	}
+}
+
+func TestRunSetupFile_RecordsTimeout(t *testing.T) {
+	shortGrace(t)
+	repo := setupRepo(t)
+	err := runSetupFile(nil, setupSpec{
+		script:       writeScript(t, "sleep 30\n"),
+		timeout:      100 * time.Millisecond,
+		worktreePath: repo,
+		repoRoot:     repo,
+	})
+	if !errors.Is(err, ErrSetupTimedOut) || err.Error() != "setup timed out after 100ms" {
+		t.Fatalf("err = %v", err)
+	}
+	st, _, _ := ReadSetupStatus(repo)
+	if st.State != SetupTimedOut || !st.failed() {
+		t.Errorf("recorded state = %q", st.State)
+	}
+}
@@ -0,0 +1,53 @@
+package worktree
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// ShellConfig holds inputs for Shell.
+type ShellConfig struct {
+	WorktreePath string
+	BranchName   string
+	RepoRoot     string
+	OriginalDir  string
+}
+
+// Shell starts an interactive $SHELL inside the worktree with the standard
+// .wtwrc variables exported, plus WTW_WORKTREE so prompts can show where they
+// are. It returns when the shell exits; the shell's own exit status is not
+// treated as an error.
+func Shell(cfg ShellConfig) error {
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+	if outer := os.Getenv("WTW_WORKTREE"); outer != "" {
+		fmt.Printf("Note: already inside a wtw shell for %s.\n", outer)
+	}
+
+	cmd := exec.Command(shell)
+	cmd.Dir = cfg.WorktreePath
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), shellEnv(cfg)...)
+
+	fmt.Printf("Entering %s — exit to return.\n", cfg.WorktreePath)
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return fmt.Errorf("failed to start %s: %w", shell, err)
+	}
+	return nil
+}
+
+// shellEnv returns the variables exported into a wtw shell.
+func shellEnv(cfg ShellConfig) []string {
+	return append(scriptEnv(cfg.WorktreePath, cfg.BranchName, cfg.RepoRoot, cfg.OriginalDir),
+		"WTW_WORKTREE="+filepath.Base(cfg.WorktreePath),
+	)
+}
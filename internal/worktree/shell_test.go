@@ -0,0 +1,45 @@
+package worktree
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestShell_ExportsVarsAndMarker(t *testing.T) {
+	dir := t.TempDir()
+	worktreePath := filepath.Join(dir, "myapp-feature-x")
+	if err := os.MkdirAll(worktreePath, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// A fake $SHELL that records what it sees and exits non-zero, which Shell
+	// must not treat as a failure.
+	fake := filepath.Join(dir, "fake-shell")
+	script := "#!/bin/sh\necho \"$PWD|$BRANCH_NAME|$WTW_WORKTREE\" > \"$ORIGINAL_DIR/out\"\nexit 3\n"
+	if err := os.WriteFile(fake, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SHELL", fake)
+	t.Setenv("WTW_WORKTREE", "")
+
+	err := Shell(ShellConfig{
+		WorktreePath: worktreePath,
+		BranchName:   "feature/x",
+		RepoRoot:     filepath.Join(dir, "myapp"),
+		OriginalDir:  dir,
+	})
+	if err != nil {
+		t.Fatalf("Shell: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := worktreePath + "|feature/x|myapp-feature-x"
+	if got := strings.TrimSpace(string(data)); got != want {
+		t.Errorf("shell saw %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,553 @@
+package worktree
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"wtw/internal/interp"
+	"wtw/internal/ui"
+)
+
+// StepsFileName is the declarative alternative to .wtwrc.
+const StepsFileName = ".wtw.yml"
+
+// Step is one entry of a declarative setup file. Exactly one action field is
+// set; Name defaults to a description of the action.
+type Step struct {
+	Name        string        `yaml:"name"`
+	Copy        *PathStep     `yaml:"copy"`
+	Symlink     *PathStep     `yaml:"symlink"`
+	EnvSet      *EnvSetStep   `yaml:"env-set"`
+	Template    *TemplateStep `yaml:"template"`
+	Run         string        `yaml:"run"`
+	WaitForPort *WaitForPort  `yaml:"wait-for-port"`
+}
+
+// PathStep copies, links or renders From (relative to the main repo) to To
+// (relative to the worktree). To defaults to From.
+type PathStep struct {
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+}
+
+// TemplateStep renders From to To like `wtw template`.
+type TemplateStep struct {
+	PathStep `yaml:",inline"`
+	Strict   bool `yaml:"strict"` // undefined variables fail the step
+}
+
+// EnvSetStep sets keys in an env file in the worktree, like `wtw env-set`.
+type EnvSetStep struct {
+	File   string     `yaml:"file"`
+	Values orderedMap `yaml:"values"`
+}
+
+// WaitForPort waits until a TCP port accepts connections.
+type WaitForPort struct {
+	Host    string `yaml:"host"` // defaults to localhost
+	Port    int    `yaml:"port"`
+	Timeout string `yaml:"timeout"` // Go duration, defaults to 30s
+}
+
+// orderedMap is a YAML mapping that keeps its keys in file order.
+type orderedMap [][2]string
+
+func (m *orderedMap) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: expected a mapping of KEY: value", n.Line)
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		*m = append(*m, [2]string{n.Content[i].Value, n.Content[i+1].Value})
+	}
+	return nil
+}
+
+// StepSelection narrows which steps run. Only and Skip hold step names.
+type StepSelection struct {
+	Only []string `json:"only,omitempty"`
+	Skip []string `json:"skip,omitempty"`
+}
+
+// IsStepsFile reports whether path is a declarative setup file rather than a
+// shell script.
+func IsStepsFile(path string) bool {
+	ext := filepath.Ext(path)
+	return ext == ".yml" || ext == ".yaml"
+}
+
+// LoadSteps parses a declarative setup file.
+func LoadSteps(path string) ([]Step, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Steps []Step `yaml:"steps"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	for i := range file.Steps {
+		if err := file.Steps[i].validate(); err != nil {
+			return nil, fmt.Errorf("%s: step %d: %w", filepath.Base(path), i+1, err)
+		}
+	}
+	return file.Steps, nil
+}
+
+// validate checks that exactly one action is set and fills in the name.
+func (s *Step) validate() error {
+	var kinds []string
+	if s.Copy != nil {
+		kinds = append(kinds, "copy")
+	}
+	if s.Symlink != nil {
+		kinds = append(kinds, "symlink")
+	}
+	if s.EnvSet != nil {
+		kinds = append(kinds, "env-set")
+	}
+	if s.Template != nil {
+		kinds = append(kinds, "template")
+	}
+	if s.Run != "" {
+		kinds = append(kinds, "run")
+	}
+	if s.WaitForPort != nil {
+		kinds = append(kinds, "wait-for-port")
+	}
+	if len(kinds) != 1 {
+		return fmt.Errorf("expected exactly one of copy, symlink, env-set, template, run, wait-for-port; got %d", len(kinds))
+	}
+
+	paths := []*PathStep{s.Copy, s.Symlink}
+	if s.Template != nil {
+		paths = append(paths, &s.Template.PathStep)
+	}
+	for _, p := range paths {
+		if p != nil && p.From == "" {
+			return fmt.Errorf("%s: 'from' is required", kinds[0])
+		}
+	}
+	if s.EnvSet != nil && s.EnvSet.File == "" {
+		return errors.New("env-set: 'file' is required")
+	}
+	if s.WaitForPort != nil && s.WaitForPort.Port == 0 {
+		return errors.New("wait-for-port: 'port' is required")
+	}
+
+	if s.Name == "" {
+		s.Name = s.describe(kinds[0])
+	}
+	return nil
+}
+
+// describe returns the default name of a step, e.g. "copy .env".
+func (s *Step) describe(kind string) string {
+	switch kind {
+	case "copy":
+		return kind + " " + s.Copy.From
+	case "symlink":
+		return kind + " " + s.Symlink.From
+	case "template":
+		return kind + " " + s.Template.From
+	case "env-set":
+		return kind + " " + s.EnvSet.File
+	case "wait-for-port":
+		return kind + " " + strconv.Itoa(s.WaitForPort.Port)
+	}
+	line, _, _ := strings.Cut(s.Run, "\n")
+	return "run " + line
+}
+
+// stepEnv is the context a step runs in.
+type stepEnv struct {
+	worktreePath string
+	repoRoot     string
+	vars         []string // KEY=VALUE, as passed to .wtwrc
+	stdout       io.Writer
+	stderr       io.Writer
+	deadline     time.Time // for run steps; zero means none
+}
+
+// expand substitutes ${VAR} and $VAR from the standard variables, falling
+// back to the process environment.
+func (e stepEnv) expand(s string) string {
+	return os.Expand(s, e.lookup)
+}
+
+// expandBare substitutes $VAR like expand, leaving ${...} and $$ for the
+// env-set filters to expand.
+func (e stepEnv) expandBare(s string) string {
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(s, '$')
+		if i < 0 || i+1 == len(s) {
+			b.WriteString(s)
+			return b.String()
+		}
+		b.WriteString(s[:i])
+		n := i + 1
+		for n < len(s) && (isNameByte(s[n]) || n > i+1 && '0' <= s[n] && s[n] <= '9') {
+			n++
+		}
+		switch {
+		case s[i+1] == '{' || s[i+1] == '$':
+			b.WriteString(s[i : i+2])
+			n = i + 2
+		case n == i+1:
+			b.WriteByte('$')
+		default:
+			b.WriteString(e.lookup(s[i+1 : n]))
+		}
+		s = s[n:]
+	}
+}
+
+// isNameByte reports whether c can start a variable name.
+func isNameByte(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func (e stepEnv) lookup(key string) string {
+	for _, kv := range e.vars {
+		if k, v, _ := strings.Cut(kv, "="); k == key {
+			return v
+		}
+	}
+	return os.Getenv(key)
+}
+
+// stepResult records the outcome of one step for the final report.
+type stepResult struct {
+	name     string
+	status   string // "ok", "failed", "skipped", "not run"
+	duration time.Duration
+	err      error
+}
+
+// RunSteps executes a declarative setup file in the worktree. Steps run in
+// order and stop at the first failure; a report with each step's status and
+// timing is printed at the end. Output is teed into a setup log in the
+// worktree's git dir.
+func RunSteps(path string, sel StepSelection, worktreePath, branchName, repoRoot, originalDir string) error {
+	return runSetupFile(nil, setupSpec{
+		script:       path,
+		steps:        sel,
+		worktreePath: worktreePath,
+		branchName:   branchName,
+		repoRoot:     repoRoot,
+		originalDir:  originalDir,
+	})
+}
+
+func runSteps(run *setupRun, spec setupSpec, vars []string) error {
+	steps, err := LoadSteps(spec.script)
+	if err != nil {
+		return err
+	}
+	sel := spec.steps
+	if err := checkSelection(steps, sel); err != nil {
+		return err
+	}
+
+	env := stepEnv{
+		worktreePath: spec.worktreePath,
+		repoRoot:     spec.repoRoot,
+		vars:         vars,
+		stdout:       run.stdout,
+		stderr:       run.stderr,
+		deadline:     run.deadline,
+	}
+
+	results := make([]stepResult, 0, len(steps))
+	var failed error
+	for _, s := range steps {
+		r := stepResult{name: s.Name}
+		switch {
+		case !sel.includes(s.Name):
+			r.status = "skipped"
+		case failed != nil:
+			r.status = "not run"
+		case slices.Contains(spec.done, s.Name):
+			r.status = "done"
+		default:
+			fmt.Fprintf(env.stdout, "▸ %s\n", s.Name)
+			start := time.Now()
+			r.err = s.exec(env)
+			r.duration = time.Since(start)
+			r.status = "ok"
+			if r.err != nil {
+				r.status = "failed"
+				failed = fmt.Errorf("step %q failed: %w", s.Name, r.err)
+			} else {
+				recordSetupProgress(spec, s.Name)
+			}
+		}
+		results = append(results, r)
+	}
+
+	printStepReport(results, run)
+	return failed
+}
+
+// checkSelection rejects --only/--skip names that match no step.
+func checkSelection(steps []Step, sel StepSelection) error {
+	known := make(map[string]bool, len(steps))
+	for _, s := range steps {
+		known[s.Name] = true
+	}
+	for _, name := range append(append([]string{}, sel.Only...), sel.Skip...) {
+		if !known[name] {
+			return fmt.Errorf("no setup step named %q", name)
+		}
+	}
+	return nil
+}
+
+// includes reports whether the step called name should run.
+func (sel StepSelection) includes(name string) bool {
+	for _, s := range sel.Skip {
+		if s == name {
+			return false
+		}
+	}
+	if len(sel.Only) == 0 {
+		return true
+	}
+	for _, o := range sel.Only {
+		if o == name {
+			return true
+		}
+	}
+	return false
+}
+
+// printStepReport prints one line per step, and logs it to run.
+func printStepReport(results []stepResult, run *setupRun) {
+	width := 0
+	for _, r := range results {
+		width = max(width, len(r.name))
+	}
+	fmt.Println()
+	for _, r := range results {
+		line := fmt.Sprintf("%-*s  %-7s", width, r.name, r.status)
+		if r.status == "ok" || r.status == "failed" {
+			line += "  " + r.duration.Round(time.Millisecond).String()
+		}
+		if r.err != nil {
+			line += ": " + r.err.Error()
+		}
+		run.logf("%s", line)
+		switch r.status {
+		case "ok":
+			ui.Success(line)
+		case "failed":
+			ui.Error(line)
+		default:
+			fmt.Println("  " + line)
+		}
+	}
+}
+
+// exec runs the step's action.
+func (s *Step) exec(env stepEnv) error {
+	switch {
+	case s.Copy != nil:
+		from, to, err := s.Copy.paths(env)
+		if err != nil {
+			return err
+		}
+		return copyPath(from, to)
+	case s.Symlink != nil:
+		from, to, err := s.Symlink.paths(env)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(to), 0o755); err != nil {
+			return err
+		}
+		if err := removeFileAt(to); err != nil {
+			return err
+		}
+		return os.Symlink(from, to)
+	case s.Template != nil:
+		from, to, err := s.Template.paths(env)
+		if err != nil {
+			return err
+		}
+		out, err := renderTemplate(from, env.vars, s.Template.Strict)
+		if err != nil {
+			return err
+		}
+		return writeRendered(from, to, out)
+	case s.EnvSet != nil:
+		pairs := make([]string, 0, len(s.EnvSet.Values))
+		for _, kv := range s.EnvSet.Values {
+			pairs = append(pairs, kv[0]+"="+env.expandBare(kv[1]))
+		}
+		file, err := env.inWorktree(s.EnvSet.File)
+		if err != nil {
+			return err
+		}
+		return EnvSet(EnvSetConfig{
+			File:  file,
+			Pairs: pairs,
+			Mode:  interp.Expand,
+			Vars:  env.vars,
+		})
+	case s.WaitForPort != nil:
+		return s.WaitForPort.wait()
+	}
+	return runInline(s.Run, env)
+}
+
+// paths resolves From against the main repo and To against the worktree.
+func (p *PathStep) paths(env stepEnv) (from, to string, err error) {
+	from = env.expand(p.From)
+	if !filepath.IsAbs(from) {
+		from = filepath.Join(env.repoRoot, from)
+	}
+	to = p.To
+	if to == "" {
+		to = p.From
+	}
+	to, err = env.inWorktree(to)
+	return from, to, err
+}
+
+// inWorktree resolves a destination against the worktree, refusing one that
+// is the worktree itself or outside it.
+func (e stepEnv) inWorktree(dst string) (string, error) {
+	path := e.expand(dst)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(e.worktreePath, path)
+	}
+	path = filepath.Clean(path)
+	if path == filepath.Clean(e.worktreePath) || !insideDir(path, e.worktreePath) {
+		return "", fmt.Errorf("%s is not inside the worktree", dst)
+	}
+	return path, nil
+}
+
+// removeFileAt removes the file or symlink at path, if any, to make way for
+// a new symlink. A directory is left alone.
+func removeFileAt(path string) error {
+	info, err := os.Lstat(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return nil
+	case err != nil:
+		return err
+	case info.IsDir():
+		return fmt.Errorf("%s is a directory; remove it to link it instead", path)
+	}
+	return os.Remove(path)
+}
+
+// runInline runs a shell snippet with bash in the worktree, with the same
+// environment as a .wtwrc script.
+func runInline(script string, env stepEnv) error {
+	cmd := exec.Command("bash", "-c", script)
+	cmd.Dir = env.worktreePath
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = env.stdout
+	cmd.Stderr = env.stderr
+	cmd.Env = append(os.Environ(), env.vars...)
+	return runSetupCommand(cmd, env.deadline)
+}
+
+// wait polls the port until it accepts a TCP connection or the timeout ends.
+func (w *WaitForPort) wait() error {
+	timeout := 30 * time.Second
+	if w.Timeout != "" {
+		d, err := time.ParseDuration(w.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid timeout %q: %w", w.Timeout, err)
+		}
+		timeout = d
+	}
+	host := w.Host
+	if host == "" {
+		host = "localhost"
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(w.Port))
+
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := net.DialTimeout("tcp", addr, time.Second)
+		if err == nil {
+			return conn.Close()
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%s not reachable after %s", addr, timeout)
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+}
+
+// copyPath copies a file or directory tree from src to dst, keeping file
+// modes. Existing files at dst are overwritten.
+func copyPath(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			_ = os.Remove(target)
+			return os.Symlink(link, target)
+		default:
+			return copyFile(path, target, info.Mode().Perm())
+		}
+	})
+}
+
+// copyFile copies a regular file, creating parent directories as needed.
+func copyFile(src, dst string, perm fs.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,163 @@
+package worktree
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeSteps writes a .wtw.yml into repo and returns its path.
+func writeSteps(t *testing.T, repo, content string) string {
+	t.Helper()
+	path := filepath.Join(repo, StepsFileName)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadSteps_NamesAndValidation(t *testing.T) {
+	repo := t.TempDir()
+	path := writeSteps(t, repo, `
+steps:
+  - copy: {from: .env}
+  - name: deps
+    run: npm install
+  - wait-for-port: {port: 5432}
+`)
+	steps, err := LoadSteps(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, s := range steps {
+		names = append(names, s.Name)
+	}
+	if got := strings.Join(names, ","); got != "copy .env,deps,wait-for-port 5432" {
+		t.Errorf("names = %s", got)
+	}
+
+	bad := writeSteps(t, repo, "steps:\n  - copy: {from: a}\n    run: echo\n")
+	if _, err := LoadSteps(bad); err == nil {
+		t.Error("expected error for a step with two actions")
+	}
+}
+
+func TestRunSteps(t *testing.T) {
+	repo := t.TempDir()
+	wt := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repo, ".env"), []byte("APP_URL=http://localhost\nDEBUG=true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, "shared.txt"), []byte("shared"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, "app.conf.tmpl"), []byte("server_name ${WORKTREE_NAME};\nproxy_set_header Host $host;\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	path := writeSteps(t, repo, `
+steps:
+  - copy: {from: .env}
+  - symlink: {from: shared.txt}
+  - env-set:
+      file: .env
+      values:
+        APP_URL: http://${WORKTREE_NAME}.test
+        NEW_KEY: x
+        BRANCH: $BRANCH_NAME-${BRANCH_NAME|upper}
+  - template: {from: app.conf.tmpl, to: app.conf}
+  - name: marker
+    run: echo "$BRANCH_NAME" > marker
+  - name: skipped
+    run: touch skipped
+`)
+
+	err := RunSteps(path, StepSelection{Skip: []string{"skipped"}}, wt, "feat", repo, repo)
+	if err != nil {
+		t.Fatalf("RunSteps: %v", err)
+	}
+
+	name := filepath.Base(wt)
+	checks := map[string]string{
+		".env":     "APP_URL=http://" + name + ".test\nDEBUG=true\nNEW_KEY=x\nBRANCH=feat-FEAT\n",
+		"app.conf": "server_name " + name + ";\nproxy_set_header Host $host;\n",
+		"marker":   "feat\n",
+	}
+	for file, want := range checks {
+		data, err := os.ReadFile(filepath.Join(wt, file))
+		if err != nil {
+			t.Errorf("%s: %v", file, err)
+			continue
+		}
+		if string(data) != want {
+			t.Errorf("%s = %q, want %q", file, data, want)
+		}
+	}
+	if link, err := os.Readlink(filepath.Join(wt, "shared.txt")); err != nil || link != filepath.Join(repo, "shared.txt") {
+		t.Errorf("symlink = %q, %v", link, err)
+	}
+	if _, err := os.Stat(filepath.Join(wt, "skipped")); err == nil {
+		t.Error("skipped step ran")
+	}
+}
+
+func TestRunSteps_StopsOnFailure(t *testing.T) {
+	repo := t.TempDir()
+	wt := t.TempDir()
+	path := writeSteps(t, repo, `
+steps:
+  - name: fail
+    run: exit 1
+  - name: after
+    run: touch after
+`)
+	err := RunSteps(path, StepSelection{}, wt, "feat", repo, repo)
+	if err == nil || !strings.Contains(err.Error(), `"fail"`) {
+		t.Fatalf("err = %v, want failure of step fail", err)
+	}
+	if _, err := os.Stat(filepath.Join(wt, "after")); err == nil {
+		t.Error("step after a failure ran")
+	}
+}
+
+func TestRunSteps_RefusesTargetsOutsideWorktree(t *testing.T) {
+	repo := t.TempDir()
+	parent := t.TempDir()
+	wt := filepath.Join(parent, "wt")
+	outside := filepath.Join(parent, "outside.txt")
+	writeTree(t, repo, map[string]string{"shared.txt": "shared"})
+	writeTree(t, wt, map[string]string{"keep.txt": "keep", "dir/keep.txt": "keep"})
+	writeTree(t, parent, map[string]string{"outside.txt": "outside"})
+
+	for _, step := range []string{
+		"symlink: {from: .}",
+		"symlink: {from: shared.txt, to: ..}",
+		"symlink: {from: shared.txt, to: " + outside + "}",
+		"copy: {from: shared.txt, to: ../outside.txt}",
+		"template: {from: shared.txt, to: ../outside.txt}",
+		"env-set: {file: ../outside.txt, values: {A: b}}",
+		"symlink: {from: shared.txt, to: dir}",
+	} {
+		path := writeSteps(t, repo, "steps:\n  - "+step+"\n")
+		if err := RunSteps(path, StepSelection{}, wt, "feat", repo, repo); err == nil {
+			t.Errorf("%s: succeeded", step)
+		}
+	}
+	for _, file := range []string{filepath.Join(wt, "keep.txt"), filepath.Join(wt, "dir", "keep.txt"), outside} {
+		if info, err := os.Lstat(file); err != nil || !info.Mode().IsRegular() {
+			t.Errorf("%s was removed or replaced: %v", file, err)
+		}
+	}
+	if data, _ := os.ReadFile(outside); string(data) != "outside" {
+		t.Errorf("outside.txt = %q", data)
+	}
+}
+
+func TestRunSteps_UnknownSelection(t *testing.T) {
+	repo := t.TempDir()
+	path := writeSteps(t, repo, "steps:\n  - run: echo hi\n")
+	if err := RunSteps(path, StepSelection{Only: []string{"nope"}}, t.TempDir(), "b", repo, repo); err == nil {
+		t.Error("expected error for unknown step name")
+	}
+}
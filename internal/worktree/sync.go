@@ -0,0 +1,216 @@
+package worktree
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"wtw/internal/git"
+	"wtw/internal/ui"
+)
+
+// SyncMode selects how a branch is brought up to date with its base.
+type SyncMode string
+
+const (
+	SyncRebase SyncMode = "rebase"
+	SyncMerge  SyncMode = "merge"
+)
+
+// SyncConfig holds inputs for Sync.
+type SyncConfig struct {
+	RepoRoot string   // main repo root
+	Branches []string // branches whose worktrees should be synced
+	All      bool     // sync every worktree; Branches is ignored
+	Mode     SyncMode // defaults to SyncRebase
+}
+
+// Sync status values reported per worktree.
+const (
+	syncUpdated      = "updated"
+	syncUpToDate     = "up to date"
+	syncSkipped      = "skipped"
+	syncConflict     = "conflict"
+	syncNotAttempted = "not attempted"
+)
+
+// SyncResult is the outcome of syncing a single worktree.
+type SyncResult struct {
+	Branch string
+	Path   string
+	Status string
+	Detail string
+}
+
+// Sync fetches once, then brings each selected worktree up to date: branches
+// that track an upstream are fast-forwarded, and branches with a recorded base
+// are rebased or merged onto it. Dirty worktrees are skipped. A conflict
+// aborts the in-progress rebase/merge and stops the run.
+func Sync(cfg SyncConfig) error {
+	results, err := syncWorktrees(cfg)
+	if err != nil {
+		return err
+	}
+
+	width := 0
+	for _, r := range results {
+		width = max(width, len(r.Branch))
+	}
+	var conflict *SyncResult
+	for i, r := range results {
+		line := fmt.Sprintf("%-*s  %s", width, r.Branch, r.Status)
+		if r.Detail != "" {
+			line += ": " + r.Detail
+		}
+		switch r.Status {
+		case syncUpdated:
+			ui.Success(line)
+		case syncConflict:
+			ui.Error(line)
+			conflict = &results[i]
+		default:
+			fmt.Println("  " + line)
+		}
+	}
+
+	if conflict != nil {
+		return fmt.Errorf("sync stopped: resolve the conflict in %s manually", conflict.Path)
+	}
+	return nil
+}
+
+// syncWorktrees does the work for Sync and returns one result per selected
+// worktree, in `git worktree list` order.
+func syncWorktrees(cfg SyncConfig) ([]SyncResult, error) {
+	mode := cfg.Mode
+	if mode == "" {
+		mode = SyncRebase
+	}
+	if mode != SyncRebase && mode != SyncMerge {
+		return nil, fmt.Errorf("unknown sync mode %q", mode)
+	}
+
+	worktrees, err := git.ListWorktrees(cfg.RepoRoot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list worktrees: %w", err)
+	}
+	targets, err := selectSyncTargets(worktrees, cfg.Branches, cfg.All)
+	if err != nil {
+		return nil, err
+	}
+
+	if remotes, _ := git.OutputIn(cfg.RepoRoot, "remote"); remotes != "" {
+		if out, err := git.CombinedOutputIn(cfg.RepoRoot, "fetch", "--all", "--prune"); err != nil {
+			return nil, fmt.Errorf("fetch failed: %s", out)
+		}
+	}
+
+	results := make([]SyncResult, 0, len(targets))
+	stopped := false
+	for _, wt := range targets {
+		r := SyncResult{Branch: wt.Branch, Path: wt.Path}
+		if wt.Branch == "" {
+			r.Branch = "(detached)"
+		}
+		if stopped {
+			r.Status = syncNotAttempted
+		} else {
+			syncOne(cfg.RepoRoot, wt, mode, &r)
+			stopped = r.Status == syncConflict
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// selectSyncTargets picks the worktrees named by branches, or all of them.
+func selectSyncTargets(worktrees []git.Worktree, branches []string, all bool) ([]git.Worktree, error) {
+	if all {
+		return worktrees, nil
+	}
+	if len(branches) == 0 {
+		return nil, errors.New("no worktrees selected: pass branch names or --all")
+	}
+	var targets []git.Worktree
+	for _, b := range branches {
+		found := false
+		for _, wt := range worktrees {
+			if wt.Branch == b {
+				targets = append(targets, wt)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no worktree for branch %q", b)
+		}
+	}
+	return targets, nil
+}
+
+// syncOne updates a single worktree and fills in r.Status and r.Detail.
+func syncOne(repoRoot string, wt git.Worktree, mode SyncMode, r *SyncResult) {
+	if wt.Branch == "" {
+		r.Status, r.Detail = syncSkipped, "detached HEAD"
+		return
+	}
+	if git.IsDirty(wt.Path) {
+		r.Status, r.Detail = syncSkipped, "uncommitted changes"
+		return
+	}
+
+	before, _ := git.OutputIn(wt.Path, "rev-parse", "HEAD")
+	var steps []string
+
+	upstream := git.Upstream(wt.Path, wt.Branch)
+	if upstream != "" {
+		if _, err := git.CombinedOutputIn(wt.Path, "merge", "--ff-only", upstream); err != nil {
+			r.Status, r.Detail = syncSkipped, "diverged from "+upstream
+			return
+		}
+		steps = append(steps, "fast-forwarded to "+upstream)
+	}
+
+	base := git.BranchBase(repoRoot, wt.Branch)
+	if base != "" && base != wt.Branch {
+		// Prefer the freshly fetched remote copy of the base when it has one.
+		onto := base
+		if up := git.Upstream(repoRoot, base); up != "" {
+			onto = up
+		}
+		var args, abort []string
+		if mode == SyncMerge {
+			args, abort = []string{"merge", "--no-edit", onto}, []string{"merge", "--abort"}
+		} else {
+			args, abort = []string{"rebase", onto}, []string{"rebase", "--abort"}
+		}
+		if out, err := git.CombinedOutputIn(wt.Path, args...); err != nil {
+			_, _ = git.CombinedOutputIn(wt.Path, abort...)
+			r.Status = syncConflict
+			r.Detail = fmt.Sprintf("%s onto %s failed and was aborted", mode, onto)
+			if line := lastLine(out); line != "" {
+				r.Detail += " (" + line + ")"
+			}
+			return
+		}
+		steps = append(steps, string(mode)+"d onto "+onto)
+	}
+
+	if upstream == "" && (base == "" || base == wt.Branch) {
+		r.Status, r.Detail = syncSkipped, "no recorded base or upstream"
+		return
+	}
+
+	after, _ := git.OutputIn(wt.Path, "rev-parse", "HEAD")
+	if after == before {
+		r.Status = syncUpToDate
+		return
+	}
+	r.Status, r.Detail = syncUpdated, strings.Join(steps, ", ")
+}
+
+// lastLine returns the last non-empty line of s.
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
@@ -0,0 +1,145 @@
+package worktree
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"wtw/internal/git"
+)
+
+// gitIn runs git in dir and fails the test on error.
+func gitIn(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// commitFile writes name with content in dir and commits it.
+func commitFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitIn(t, dir, "add", name)
+	gitIn(t, dir, "commit", "-m", "update "+name)
+}
+
+// createWorktree creates a worktree for branch off the repo's current branch
+// and returns its path.
+func createWorktree(t *testing.T, repoRoot, branch string) string {
+	t.Helper()
+	cfg := CreateConfig{
+		BranchName:  branch,
+		RepoRoot:    repoRoot,
+		RepoName:    filepath.Base(repoRoot),
+		OriginalDir: repoRoot,
+	}
+	if err := Create(cfg); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	path := filepath.Join(filepath.Dir(repoRoot), filepath.Base(repoRoot)+"-"+SanitizeBranch(branch))
+	t.Cleanup(func() { _ = os.RemoveAll(path) })
+	return path
+}
+
+func TestCreate_RecordsBase(t *testing.T) {
+	repoRoot := setupRepo(t)
+	base := git.CurrentBranch(repoRoot)
+	createWorktree(t, repoRoot, "feat")
+
+	if got := git.BranchBase(repoRoot, "feat"); got != base {
+		t.Errorf("BranchBase = %q, want %q", got, base)
+	}
+}
+
+func TestSync_RebasesOntoBase(t *testing.T) {
+	repoRoot := setupRepo(t)
+	wt := createWorktree(t, repoRoot, "feat")
+	commitFile(t, wt, "feat.txt", "feature\n")
+	commitFile(t, repoRoot, "main.txt", "main\n")
+
+	results, err := syncWorktrees(SyncConfig{RepoRoot: repoRoot, Branches: []string{"feat"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Status != syncUpdated {
+		t.Fatalf("results = %+v, want one updated", results)
+	}
+	if _, err := os.Stat(filepath.Join(wt, "main.txt")); err != nil {
+		t.Errorf("main.txt not present after rebase: %v", err)
+	}
+	if n := gitIn(t, wt, "rev-list", "--merges", "--count", "HEAD"); n != "0" {
+		t.Errorf("rebase produced %s merge commits", n)
+	}
+}
+
+func TestSync_Merge(t *testing.T) {
+	repoRoot := setupRepo(t)
+	wt := createWorktree(t, repoRoot, "feat")
+	commitFile(t, wt, "feat.txt", "feature\n")
+	commitFile(t, repoRoot, "main.txt", "main\n")
+
+	results, err := syncWorktrees(SyncConfig{RepoRoot: repoRoot, Branches: []string{"feat"}, Mode: SyncMerge})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Status != syncUpdated {
+		t.Fatalf("status = %q, want %q", results[0].Status, syncUpdated)
+	}
+	if n := gitIn(t, wt, "rev-list", "--merges", "--count", "HEAD"); n != "1" {
+		t.Errorf("merge commits = %s, want 1", n)
+	}
+}
+
+func TestSync_SkipsDirty(t *testing.T) {
+	repoRoot := setupRepo(t)
+	wt := createWorktree(t, repoRoot, "feat")
+	commitFile(t, repoRoot, "main.txt", "main\n")
+	if err := os.WriteFile(filepath.Join(wt, "wip.txt"), []byte("wip"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := syncWorktrees(SyncConfig{RepoRoot: repoRoot, Branches: []string{"feat"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Status != syncSkipped {
+		t.Errorf("status = %q, want %q", results[0].Status, syncSkipped)
+	}
+}
+
+func TestSync_ConflictAbortsAndStops(t *testing.T) {
+	repoRoot := setupRepo(t)
+	a := createWorktree(t, repoRoot, "a")
+	createWorktree(t, repoRoot, "b")
+	commitFile(t, a, "same.txt", "from a\n")
+	commitFile(t, repoRoot, "same.txt", "from main\n")
+
+	results, err := syncWorktrees(SyncConfig{RepoRoot: repoRoot, Branches: []string{"a", "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Status != syncConflict {
+		t.Errorf("a: status = %q, want %q", results[0].Status, syncConflict)
+	}
+	if results[1].Status != syncNotAttempted {
+		t.Errorf("b: status = %q, want %q", results[1].Status, syncNotAttempted)
+	}
+	if git.IsDirty(a) {
+		t.Error("worktree a left dirty after aborted rebase")
+	}
+}
+
+func TestSync_UnknownBranch(t *testing.T) {
+	repoRoot := setupRepo(t)
+	if _, err := syncWorktrees(SyncConfig{RepoRoot: repoRoot, Branches: []string{"nope"}}); err == nil {
+		t.Fatal("expected error for branch without a worktree")
+	}
+}
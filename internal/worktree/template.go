@@ -0,0 +1,153 @@
+package worktree
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"wtw/internal/git"
+	"wtw/internal/interp"
+	"wtw/internal/slug"
+	"wtw/internal/trust"
+	"wtw/internal/ui"
+)
+
+// TemplateConfig holds inputs for Template.
+type TemplateConfig struct {
+	Src          string
+	Dst          string
+	WorktreePath string
+	RepoRoot     string
+	OriginalDir  string
+	Strict       bool // undefined variables are an error
+	Check        bool // report drift from Dst instead of writing it
+}
+
+// Template renders Src to Dst with the worktree's variables. With cfg.Check
+// it writes nothing and fails when Dst differs from the rendered output.
+func Template(cfg TemplateConfig) error {
+	vars := scriptEnv(cfg.WorktreePath, git.CurrentBranch(cfg.WorktreePath), cfg.RepoRoot, cfg.OriginalDir)
+	out, err := renderTemplate(cfg.Src, vars, cfg.Strict)
+	if err != nil {
+		return err
+	}
+	if !cfg.Check {
+		return writeRendered(cfg.Src, cfg.Dst, out)
+	}
+
+	current, err := os.ReadFile(cfg.Dst)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return fmt.Errorf("%s does not exist; render it with: wtw template %s %s", cfg.Dst, cfg.Src, cfg.Dst)
+	case err != nil:
+		return err
+	case bytes.Equal(current, out):
+		ui.Success(cfg.Dst + " is up to date")
+		return nil
+	}
+	fmt.Print(trust.Diff(string(current), string(out)))
+	return fmt.Errorf("%s differs from what %s renders", cfg.Dst, cfg.Src)
+}
+
+// renderTemplate renders the file at src. A src ending in .gotmpl is a Go
+// template whose data is the variables by name, as in {{.BRANCH_NAME}}, and
+// which reads the process environment with {{env "NAME"}}. Anything else has
+// ${VAR} placeholders with the env-set filters; other ${...}, such as
+// compose's ${PORT:-3000}, and undefined variables are left as written.
+func renderTemplate(src string, vars []string, strict bool) ([]byte, error) {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return nil, err
+	}
+	text := string(data)
+
+	if filepath.Ext(src) != ".gotmpl" {
+		mode := interp.Keep
+		if strict {
+			mode = interp.Strict
+		}
+		out, err := interp.Interpolate(text, mode, interp.Chain(interp.Vars(vars), os.LookupEnv))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", src, err)
+		}
+		return []byte(out), nil
+	}
+
+	values := make(map[string]string, len(vars))
+	for _, kv := range vars {
+		k, v, _ := strings.Cut(kv, "=")
+		values[k] = v
+	}
+	missing := "missingkey=zero"
+	if strict {
+		missing = "missingkey=error"
+	}
+	tmpl, err := template.New(filepath.Base(src)).Option(missing).Funcs(templateFuncs(strict)).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, values); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// templateFuncs are the functions Go templates can call, mirroring the
+// env-set filters: {{.BRANCH_NAME | db}}, {{slug "dns" .BRANCH_NAME}},
+// {{env "HOME"}}, {{.PORT | default "3000"}}.
+func templateFuncs(strict bool) template.FuncMap {
+	slugOf := func(style slug.Style) func(string) (string, error) {
+		return func(v string) (string, error) { return slug.Make(v, style, 0) }
+	}
+	return template.FuncMap{
+		"env": func(name string) (string, error) {
+			v, ok := os.LookupEnv(name)
+			if !ok && strict {
+				return "", fmt.Errorf("%w %s", interp.ErrUndefined, name)
+			}
+			return v, nil
+		},
+		"slug": func(style, v string) (string, error) {
+			s, err := slug.ParseStyle(style)
+			if err != nil {
+				return "", err
+			}
+			return slug.Make(v, s, 0)
+		},
+		"lower": strings.ToLower,
+		"upper": strings.ToUpper,
+		"snake": slugOf(slug.Snake),
+		"kebab": slugOf(slug.Kebab),
+		"dns":   slugOf(slug.DNS),
+		"db":    slugOf(slug.DB),
+		"max": func(n int, v string) string {
+			if r := []rune(v); len(r) > n {
+				return string(r[:n])
+			}
+			return v
+		},
+		"default": func(def, v string) string {
+			if v == "" {
+				return def
+			}
+			return v
+		},
+	}
+}
+
+// writeRendered writes a rendered template to dst with the mode of src.
+func writeRendered(src, dst string, out []byte) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(dst, out, info.Mode().Perm())
+}
@@ -0,0 +1,107 @@
+package worktree
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"wtw/internal/interp"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	dir := t.TempDir()
+	vars := []string{"BRANCH_NAME=feature/Login", "WTW_PORT=41000", "WORKTREE_NAME=app-feature-Login"}
+	t.Setenv("WTW_TEST_HOST", "db.local")
+
+	cases := map[string]string{
+		"name: ${BRANCH_NAME|kebab}\nports: [\"${WTW_PORT}:3000\"]\ndb: ${WTW_TEST_HOST}\n": "name: feature-login\nports: [\"41000:3000\"]\ndb: db.local\n",
+		"${NOPE} ${NOPE|upper} ${NOPE|default:x}":                                           "${NOPE} ${NOPE|upper} x",
+		"{{.BRANCH_NAME}} {{ if .Values.x }}":                                               "{{.BRANCH_NAME}} {{ if .Values.x }}",
+		"server_name {{.BRANCH_NAME | dns}}.localhost;\nlisten {{.WTW_PORT}};\n.gotmpl":     "server_name feature-login.localhost;\nlisten 41000;\n",
+		`{{slug "db" .BRANCH_NAME}} {{env "WTW_TEST_HOST"}} {{.NOPE | default "x"}}.gotmpl`: "feature_login db.local x",
+		"${NOPE}|{{/* go */}}{{.NOPE}}|.gotmpl":                                             "${NOPE}||",
+	}
+	for src, want := range cases {
+		// A case ending in .gotmpl is written to a file with that extension.
+		path := filepath.Join(dir, "tmpl")
+		if text, ok := strings.CutSuffix(src, ".gotmpl"); ok {
+			path, src = filepath.Join(dir, "tmpl.gotmpl"), text
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := renderTemplate(path, vars, false)
+		if err != nil || string(got) != want {
+			t.Errorf("render %q = %q, %v; want %q", src, got, err, want)
+		}
+	}
+}
+
+func TestRenderTemplate_KeepsComposeDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "compose.tmpl")
+	src := "name: ${BRANCH_NAME|kebab}\nports: [\"${DB_PORT:-5432}:5432\"]\nimage: ${IMAGE:?set IMAGE}\n"
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	want := "name: main\nports: [\"${DB_PORT:-5432}:5432\"]\nimage: ${IMAGE:?set IMAGE}\n"
+	for _, strict := range []bool{false, true} {
+		got, err := renderTemplate(path, []string{"BRANCH_NAME=main"}, strict)
+		if err != nil || string(got) != want {
+			t.Errorf("strict=%v: got %q, %v; want %q", strict, got, err, want)
+		}
+	}
+}
+
+func TestRenderTemplate_Strict(t *testing.T) {
+	dir := t.TempDir()
+	for _, src := range []string{"x=${NOPE}", "x={{.NOPE}}", `x={{env "WTW_TEST_UNSET"}}`} {
+		path := filepath.Join(dir, "tmpl")
+		if strings.Contains(src, "{{") {
+			path = filepath.Join(dir, "tmpl.gotmpl")
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := renderTemplate(path, nil, false); err != nil {
+			t.Errorf("%q: not strict: %v", src, err)
+		}
+		if _, err := renderTemplate(path, nil, true); err == nil {
+			t.Errorf("%q: strict rendering succeeded", src)
+		}
+	}
+	path := filepath.Join(dir, "tmpl")
+	_ = os.WriteFile(path, []byte("${NOPE}"), 0o644)
+	if _, err := renderTemplate(path, nil, true); !errors.Is(err, interp.ErrUndefined) {
+		t.Errorf("err = %v, want ErrUndefined", err)
+	}
+}
+
+func TestTemplate_Check(t *testing.T) {
+	repo := setupRepo(t)
+	src := filepath.Join(repo, "compose.tmpl")
+	dst := filepath.Join(repo, "docker-compose.override.yml")
+	writeTree(t, repo, map[string]string{"compose.tmpl": "name: ${REPO_NAME}\n"})
+	cfg := TemplateConfig{Src: src, Dst: dst, WorktreePath: repo, RepoRoot: repo, OriginalDir: repo}
+
+	check := cfg
+	check.Check = true
+	if err := Template(check); err == nil {
+		t.Error("check passed with dst missing")
+	}
+	if err := Template(cfg); err != nil {
+		t.Fatal(err)
+	}
+	captureStdout(t, func() error { return Template(check) })
+
+	writeTree(t, repo, map[string]string{"docker-compose.override.yml": "name: edited\n"})
+	var err error
+	out := captureStdout(t, func() error { err = Template(check); return nil })
+	if err == nil || !strings.Contains(out, "- name: edited") || !strings.Contains(out, "+ name: "+filepath.Base(repo)) {
+		t.Errorf("check of a drifted file: err = %v, diff:\n%s", err, out)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "name: edited\n" {
+		t.Error("check wrote the file")
+	}
+}
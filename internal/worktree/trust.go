@@ -0,0 +1,239 @@
+package worktree
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"wtw/internal/git"
+	"wtw/internal/trust"
+	"wtw/internal/ui"
+)
+
+// isInteractive is swapped out in tests.
+var isInteractive = ui.IsInteractive
+
+// ensureTrusted checks a setup script against the trust store before it runs.
+// Scripts outside repoRoot were picked by the user with -c and are always
+// allowed; scripts that live in the repo must match the last version the user
+// approved. A new or changed script is shown (as a diff when possible) and,
+// in an interactive session, the user may approve it on the spot.
+// asked reports whether the user was prompted, so callers can skip their own
+// "run it?" confirmation. Every script of a .wtwrc.d directory is checked.
+func ensureTrusted(scriptPath, repoRoot string) (asked bool, err error) {
+	if !insideDir(scriptPath, repoRoot) {
+		return false, nil
+	}
+	mainRoot := trustRoot(repoRoot)
+	if !isSetupDir(scriptPath) {
+		return ensureTrustedFile(scriptPath, repoRoot, mainRoot)
+	}
+	scripts, err := setupDirScripts(scriptPath)
+	if err != nil {
+		return false, err
+	}
+	for _, s := range scripts {
+		a, err := ensureTrustedFile(s, repoRoot, mainRoot)
+		asked = asked || a
+		if err != nil {
+			return asked, err
+		}
+	}
+	return asked, nil
+}
+
+func ensureTrustedFile(scriptPath, repoRoot, mainRoot string) (asked bool, err error) {
+	content, err := os.ReadFile(scriptPath)
+	if err != nil {
+		return false, err
+	}
+	store, err := trust.LoadDefault()
+	if err != nil {
+		return false, fmt.Errorf("cannot read trust store: %w", err)
+	}
+
+	name := trust.Key(repoRoot, scriptPath)
+	stored := storedPath(scriptPath, repoRoot, mainRoot)
+	status, previous := store.Check(mainRoot, stored, content)
+	switch status {
+	case trust.Trusted:
+		return false, nil
+	case trust.New:
+		fmt.Printf("%s has not been approved in this repo:\n\n%s\n", name, indent(string(content)))
+	case trust.Changed:
+		fmt.Printf("%s changed since you last approved it:\n\n%s\n", name, trust.Diff(previous, string(content)))
+	}
+
+	if !isInteractive() {
+		return false, fmt.Errorf("%s is not trusted; review it and run 'wtw trust'", name)
+	}
+	if !ui.Confirm("Trust "+name+" and run it? [y/N]", "N") {
+		return true, fmt.Errorf("%s is not trusted; run 'wtw trust' once you have reviewed it", name)
+	}
+	store.Approve(mainRoot, stored, content)
+	if err := store.Save(); err != nil {
+		return true, fmt.Errorf("cannot save trust store: %w", err)
+	}
+	return true, nil
+}
+
+// untrustedScripts returns the names of the scripts of a setup file that
+// would need approval before running, without asking.
+func untrustedScripts(scriptPath, repoRoot string) []string {
+	if !insideDir(scriptPath, repoRoot) {
+		return nil
+	}
+	scripts := []string{scriptPath}
+	if isSetupDir(scriptPath) {
+		scripts, _ = setupDirScripts(scriptPath)
+	}
+	store, err := trust.LoadDefault()
+	if err != nil {
+		return nil
+	}
+	mainRoot := trustRoot(repoRoot)
+	var names []string
+	for _, s := range scripts {
+		content, err := os.ReadFile(s)
+		if err != nil {
+			continue
+		}
+		if status, _ := store.Check(mainRoot, storedPath(s, repoRoot, mainRoot), content); status != trust.Trusted {
+			names = append(names, trust.Key(repoRoot, s))
+		}
+	}
+	return names
+}
+
+// trustRoot returns the root under which approvals for scripts in repoRoot
+// are stored: the repository's main worktree, so approvals hold in all of its
+// worktrees, or repoRoot itself outside a git repository.
+func trustRoot(repoRoot string) string {
+	if root, err := git.MainRepoRootOf(repoRoot); err == nil {
+		return root
+	}
+	return repoRoot
+}
+
+// storedPath returns where script, which lives in repoRoot, is in mainRoot,
+// the path the trust store knows it by.
+func storedPath(script, repoRoot, mainRoot string) string {
+	return filepath.Join(mainRoot, filepath.FromSlash(trust.Key(repoRoot, script)))
+}
+
+// TrustConfig holds inputs for Trust.
+type TrustConfig struct {
+	RepoRoot string
+	Script   string // may be empty (uses the repo's setup file)
+}
+
+// Trust approves the current content of a repo's setup script, or of every
+// script in a .wtwrc.d directory.
+func Trust(cfg TrustConfig) error {
+	script, err := ResolveSetupScript(cfg.Script, cfg.RepoRoot)
+	if err != nil {
+		return err
+	}
+	if script == "" {
+		script = filepath.Join(cfg.RepoRoot, ".wtwrc")
+	}
+	scripts := []string{script}
+	if isSetupDir(script) {
+		if scripts, err = setupDirScripts(script); err != nil {
+			return err
+		}
+	}
+
+	store, err := trust.LoadDefault()
+	if err != nil {
+		return fmt.Errorf("cannot read trust store: %w", err)
+	}
+	var approved []string
+	for _, s := range scripts {
+		name, err := trustFile(store, cfg.RepoRoot, s)
+		if err != nil {
+			return err
+		}
+		if name != "" {
+			approved = append(approved, name)
+		}
+	}
+	if len(approved) == 0 {
+		return nil
+	}
+	if err := store.Save(); err != nil {
+		return fmt.Errorf("cannot save trust store: %w", err)
+	}
+	for _, name := range approved {
+		ui.Success(name + " trusted.")
+	}
+	return nil
+}
+
+// trustFile approves one script in store, showing what changed, and
+// returns its name, or "" if it was already trusted.
+func trustFile(store *trust.Store, repoRoot, script string) (string, error) {
+	content, err := os.ReadFile(script)
+	if err != nil {
+		return "", fmt.Errorf("cannot read %s: %w", script, err)
+	}
+
+	name := trust.Key(repoRoot, script)
+	status, previous := store.Check(repoRoot, script, content)
+	if status == trust.Trusted {
+		fmt.Printf("%s is already trusted.\n", name)
+		return "", nil
+	}
+	if status == trust.Changed {
+		fmt.Printf("Changes since last approval:\n\n%s\n", trust.Diff(previous, string(content)))
+	}
+	store.Approve(repoRoot, script, content)
+	return name, nil
+}
+
+// RevokeTrust removes the approval of a script in the repo, or of every
+// script in the repo when cfg.Script is empty.
+func RevokeTrust(cfg TrustConfig) error {
+	store, err := trust.LoadDefault()
+	if err != nil {
+		return fmt.Errorf("cannot read trust store: %w", err)
+	}
+	if !store.Revoke(cfg.RepoRoot, cfg.Script) {
+		return fmt.Errorf("nothing trusted for %s", cfg.RepoRoot)
+	}
+	if err := store.Save(); err != nil {
+		return fmt.Errorf("cannot save trust store: %w", err)
+	}
+	ui.Success("Trust revoked.")
+	return nil
+}
+
+// ListTrust prints every approved script.
+func ListTrust() error {
+	store, err := trust.LoadDefault()
+	if err != nil {
+		return fmt.Errorf("cannot read trust store: %w", err)
+	}
+	entries := store.List()
+	if len(entries) == 0 {
+		fmt.Println("No trusted scripts.")
+		return nil
+	}
+	for _, e := range entries {
+		fmt.Printf("%s  %s  %s  %s\n", e.Repo, e.Script, e.Hash[:12], e.TrustedAt.Local().Format("2006-01-02 15:04"))
+	}
+	return nil
+}
+
+// insideDir reports whether path is dir or somewhere beneath it.
+func insideDir(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// indent prefixes every line of s with two spaces.
+func indent(s string) string {
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	return "  " + strings.Join(lines, "\n  ") + "\n"
+}
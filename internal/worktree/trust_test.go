@@ -0,0 +1,99 @@
+package worktree
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"wtw/internal/git"
+)
+
+// isolateTrustStore points the user config dir at a fresh temp dir.
+func isolateTrustStore(t *testing.T) {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+}
+
+func TestEnsureTrusted(t *testing.T) {
+	isolateTrustStore(t)
+	repo := t.TempDir()
+	script := filepath.Join(repo, ".wtwrc")
+	if err := os.WriteFile(script, []byte("echo one\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	orig := isInteractive
+	t.Cleanup(func() { isInteractive = orig })
+	isInteractive = func() bool { return false }
+
+	if _, err := ensureTrusted(script, repo); err == nil {
+		t.Fatal("expected non-interactive run to refuse an untrusted script")
+	}
+
+	if err := Trust(TrustConfig{RepoRoot: repo}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ensureTrusted(script, repo); err != nil {
+		t.Fatalf("trusted script refused: %v", err)
+	}
+
+	if err := os.WriteFile(script, []byte("echo two\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := ensureTrusted(script, repo)
+	if err == nil || !strings.Contains(err.Error(), "not trusted") {
+		t.Fatalf("changed script: err = %v, want not trusted", err)
+	}
+}
+
+func TestEnsureTrusted_OutsideRepo(t *testing.T) {
+	isolateTrustStore(t)
+	repo := t.TempDir()
+	script := filepath.Join(t.TempDir(), "custom.sh")
+	if err := os.WriteFile(script, []byte("echo hi\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ensureTrusted(script, repo); err != nil {
+		t.Errorf("script outside the repo should not need trust: %v", err)
+	}
+}
+
+func TestCreate_FromLinkedWorktreeUsesMainRepoTrust(t *testing.T) {
+	repo := setupRepo(t)
+	isolateTrustStore(t)
+	writeTree(t, repo, map[string]string{".wtwrc": "touch setup-ran\n"})
+	for _, args := range [][]string{{"add", ".wtwrc"}, {"commit", "-m", "setup"}} {
+		if err := git.Run(repo, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := Trust(TrustConfig{RepoRoot: repo}); err != nil {
+		t.Fatal(err)
+	}
+
+	feat1 := filepath.Join(filepath.Dir(repo), filepath.Base(repo)+"-feat1")
+	feat2 := filepath.Join(filepath.Dir(repo), filepath.Base(repo)+"-feat2")
+	t.Cleanup(func() { _ = os.RemoveAll(feat1); _ = os.RemoveAll(feat2) })
+	if err := git.Run(repo, "worktree", "add", "-b", "feat1", feat1); err != nil {
+		t.Fatal(err)
+	}
+
+	orig := isInteractive
+	t.Cleanup(func() { isInteractive = orig })
+	isInteractive = func() bool { return false }
+
+	err := Create(CreateConfig{
+		BranchName:  "feat2",
+		RepoRoot:    feat1,
+		RepoName:    filepath.Base(repo),
+		OriginalDir: feat1,
+		SetupScript: filepath.Join(feat1, ".wtwrc"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(feat2, "setup-ran")); err != nil {
+		t.Errorf("setup did not run in the new worktree: %v", err)
+	}
+}
@@ -9,10 +9,12 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
-	"strings"
+	"slices"
+	"time"
 
 	"wtw/internal/git"
+	"wtw/internal/ports"
+	"wtw/internal/slug"
 	"wtw/internal/ui"
 )
 
@@ -20,7 +22,8 @@ import (
 const wtwrcTemplate = `# .wtwrc — wtw setup script
 #
 # Runs inside the new worktree directory when you create one with ` + "`wtw`" + `.
-# This file is intentionally not executable — it is run by wtw only.
+# This file is intentionally not executable — it is run by wtw only, with
+# bash unless the first line names another interpreter (#!/usr/bin/env zsh).
 #
 # Available variables:
 #   $WORKTREE_PATH   absolute path to the new worktree
@@ -29,6 +32,8 @@ const wtwrcTemplate = `# .wtwrc — wtw setup script
 #   $REPO_NAME       base name of the main repo directory (e.g. myapp)
 #   $REPO_ROOT       absolute path to the main repo
 #   $ORIGINAL_DIR    directory where ` + "`wtw`" + ` was called from
+#   $WTW_PORT        first of the worktree's allocated ports ($WTW_PORT_1, ...)
+#   $WORKTREE_INDEX  small number unique among your worktrees
 
 # Copy environment files from the main repo
 # cp "$REPO_ROOT/.env" .env
@@ -49,107 +54,172 @@ const wtwrcTemplate = `# .wtwrc — wtw setup script
 echo "Worktree ready: $BRANCH_NAME"
 `
 
-var reInvalid = regexp.MustCompile(`[^a-zA-Z0-9._-]`)
-
 // SanitizeBranch converts a branch name into a safe directory-name component.
 // "/" → "-", invalid chars → "-", consecutive dashes collapsed, leading/trailing dashes stripped.
 func SanitizeBranch(branch string) string {
-	s := strings.ReplaceAll(branch, "/", "-")
-	s = reInvalid.ReplaceAllString(s, "-")
-	for strings.Contains(s, "--") {
-		s = strings.ReplaceAll(s, "--", "-")
-	}
-	return strings.Trim(s, "-")
+	s, _ := slug.Make(branch, slug.Dir, 0)
+	return s
 }
 
 // CreateConfig holds all inputs for Create.
 type CreateConfig struct {
-	BranchName  string // may be empty (will prompt)
-	BaseDir     string // may be empty (uses parent of repo root)
-	SetupScript string // may be empty
-	RepoRoot    string
-	RepoName    string
-	OriginalDir string
+	BranchName   string // may be empty (will prompt)
+	BaseDir      string // may be empty (uses parent of repo root)
+	SetupScript  string // may be empty
+	RepoRoot     string
+	RepoName     string
+	OriginalDir  string
+	Steps        StepSelection // --only/--skip for declarative setup files
+	Clone        *CloneConfig  // if set, clones dependency dirs from the main repo
+	Ports        *ports.Config // if set, allocates a block of ports for the worktree
+	Open         *OpenConfig   // if set, opens the worktree after creation
+	Shell        bool          // drop into a subshell in the new worktree
+	Background   bool          // run the setup script detached; see Status and Wait
+	SetupTimeout time.Duration // stop the setup script after this long; 0 means no limit
+	Interpreter  string        // runs the setup script instead of its shebang; may be empty
+	SetupInputs  []string      // files the setup depends on; nil means DefaultSetupInputs
+	DryRun       bool          // print the plan instead of creating anything
+	Track        bool          // start a branch found only on a remote from it, tracking it
+	Atomic       bool          // undo everything if a step fails or is interrupted
 }
 
-// Create creates a new worktree for the given branch.
+// Create creates a new worktree for the given branch. With cfg.DryRun it
+// only prints what it would do.
 func Create(cfg CreateConfig) error {
-	branchName := cfg.BranchName
-	if branchName == "" {
-		branchName = ui.Ask("Branch name:")
-	}
-	if branchName == "" {
-		return errors.New("branch name cannot be empty")
+	p, err := planCreate(cfg)
+	if err != nil {
+		return err
 	}
-	if strings.ContainsAny(branchName, " \t\n") {
-		return errors.New("branch name cannot contain spaces")
+	if cfg.DryRun {
+		return p.print()
 	}
+	return p.execute()
+}
 
-	worktreeDirname := cfg.RepoName + "-" + SanitizeBranch(branchName)
+// execute carries out a create plan. Each step is recorded for rollback and
+// in the creation journal; with cfg.Atomic a failing step or Ctrl-C undoes
+// the steps taken so far, otherwise `wtw resume` can finish them.
+func (p *createPlan) execute() error {
+	cfg := p.cfg
+	branchName, worktreePath := p.branch, p.path
+	rb := newRollback(cfg.Atomic)
+	defer rb.commit()
 
-	var worktreePath string
 	if cfg.BaseDir != "" {
+		_, statErr := os.Stat(cfg.BaseDir)
 		if err := os.MkdirAll(cfg.BaseDir, 0o755); err != nil {
-			return fmt.Errorf("failed to create base directory: %w", err)
+			return rb.fail(fmt.Errorf("failed to create base directory: %w", err))
 		}
-		abs, err := filepath.Abs(cfg.BaseDir)
-		if err != nil {
-			return err
+		if statErr != nil {
+			rb.add("removed directory "+cfg.BaseDir, func() error { return os.Remove(cfg.BaseDir) })
 		}
-		worktreePath = filepath.Join(abs, worktreeDirname)
-	} else {
-		worktreePath = filepath.Join(filepath.Dir(cfg.RepoRoot), worktreeDirname)
 	}
-
-	// Check for existing worktree at path
-	if _, err := os.Stat(worktreePath); err == nil {
-		if git.IsRegisteredWorktree(cfg.RepoRoot, worktreePath) {
-			return fmt.Errorf("worktree already exists at: %s", worktreePath)
-		}
-		if !ui.Confirm("Directory already exists. Remove and recreate? [y/N]", "N") {
-			return errors.New("aborted")
+	if p.replaceDir {
+		var ok bool
+		rb.unlocked(func() { ok = ui.Confirm("Directory already exists. Remove and recreate? [y/N]", "N") })
+		if !ok {
+			return rb.fail(errors.New("aborted"))
 		}
 		if err := os.RemoveAll(worktreePath); err != nil {
-			return fmt.Errorf("failed to remove directory: %w", err)
+			return rb.fail(fmt.Errorf("failed to remove directory: %w", err))
 		}
 	}
 
-	// Check if branch is already checked out elsewhere
-	if existing := git.WorktreeForBranch(cfg.RepoRoot, branchName); existing != "" {
-		return fmt.Errorf("branch %q already checked out at: %s", branchName, existing)
+	err := git.Run(cfg.RepoRoot, p.gitArgs()...)
+	if ierr := rb.check(); ierr != nil {
+		err = ierr
+	}
+	if p.action != branchExisting && git.BranchExists(cfg.RepoRoot, branchName) {
+		rb.add("deleted branch "+branchName, func() error { return git.DeleteBranch(cfg.RepoRoot, branchName) })
+	}
+	if git.IsRegisteredWorktree(cfg.RepoRoot, worktreePath) {
+		rb.add("removed worktree "+worktreePath, func() error { return git.RemoveWorktree(cfg.RepoRoot, worktreePath) })
+	}
+	if err != nil {
+		if errors.Is(err, errCreateInterrupted) {
+			return rb.fail(err)
+		}
+		return rb.fail(fmt.Errorf("failed to create worktree: %w", err))
 	}
 
-	if err := git.AddWorktree(cfg.RepoRoot, worktreePath, branchName); err != nil {
-		return fmt.Errorf("failed to create worktree: %w", err)
+	// Remember where a new branch came from so `wtw sync` knows what to
+	// rebase it onto later.
+	if p.action == branchNew && p.base != "" {
+		_ = git.SetBranchBase(cfg.RepoRoot, branchName, p.base)
 	}
 
-	if cfg.SetupScript != "" {
-		if ui.Confirm("Found "+filepath.Base(cfg.SetupScript)+" — run it? [Y/n]", "Y") {
-			if err := RunScript(cfg.SetupScript, worktreePath, branchName, cfg.RepoRoot, cfg.OriginalDir); err != nil {
-				ui.Error("setup script failed. Retry: cd " + worktreePath + " && bash " + cfg.SetupScript)
-			}
-		}
+	// The worktree is added without a checkout so that the checkout, like
+	// every later step, is journaled and can be resumed.
+	if err := writeJournal(worktreePath, newJournal(p)); err != nil {
+		return rb.fail(fmt.Errorf("failed to write creation journal: %w", err))
+	}
+	failed, err := p.runSteps(rb)
+	if err != nil {
+		return err
 	}
+	rb.commit()
 
-	ui.Success("Worktree ready.")
+	if failed {
+		ui.Error("worktree created with failed steps")
+		fmt.Println("Finish with: wtw resume " + branchName)
+	} else {
+		ui.Success("Worktree ready.")
+	}
 	ui.PrintCmd("cd " + worktreePath)
+
+	if cfg.Open != nil {
+		open := *cfg.Open
+		open.WorktreePath = worktreePath
+		open.BranchName = branchName
+		open.RepoRoot = cfg.RepoRoot
+		open.OriginalDir = cfg.OriginalDir
+		if err := Open(open); err != nil {
+			ui.Error("could not open worktree: " + err.Error())
+		}
+	}
+
+	if cfg.Shell {
+		return Shell(ShellConfig{
+			WorktreePath: worktreePath,
+			BranchName:   branchName,
+			RepoRoot:     cfg.RepoRoot,
+			OriginalDir:  cfg.OriginalDir,
+		})
+	}
 	return nil
 }
 
+// removeUnder returns an undo that deletes rel inside dir.
+func removeUnder(dir, rel string) func() error {
+	return func() error { return os.RemoveAll(filepath.Join(dir, filepath.FromSlash(rel))) }
+}
+
 // RemoveConfig holds inputs for Remove.
 type RemoveConfig struct {
 	WorktreeRoot string
 	MainRepoRoot string
+	DryRun       bool // print what would be deleted instead
 }
 
 // Remove removes the current worktree after user confirmation.
 func Remove(cfg RemoveConfig) error {
+	if cfg.DryRun {
+		p, err := planRemove(cfg)
+		if err != nil {
+			return err
+		}
+		p.print()
+		return nil
+	}
 	if !ui.Confirm("Remove this worktree ("+cfg.WorktreeRoot+")? [y/N]", "N") {
 		return errors.New("aborted")
 	}
 	if err := git.RemoveWorktree(cfg.MainRepoRoot, cfg.WorktreeRoot); err != nil {
 		return fmt.Errorf("failed to remove worktree: %w", err)
 	}
+	if err := ports.Free(cfg.WorktreeRoot); err != nil {
+		ui.Error("could not free ports: " + err.Error())
+	}
 	ui.Success("Worktree removed.")
 	ui.PrintCmd("cd " + cfg.MainRepoRoot)
 	return nil
@@ -157,38 +227,84 @@ func Remove(cfg RemoveConfig) error {
 
 // RunSetupConfig holds inputs for RunSetup.
 type RunSetupConfig struct {
-	SetupScript  string // may be empty (falls back to .wtwrc)
+	SetupScript  string // may be empty (falls back to .wtw.yml, then .wtwrc)
 	WorktreeRoot string
 	MainRepoRoot string
 	OriginalDir  string
+	Steps        StepSelection
+	Timeout      time.Duration // 0 means no limit
+	Interpreter  string        // may be empty (uses the script's shebang)
+	Inputs       []string      // files the setup depends on; nil means DefaultSetupInputs
+	IfChanged    bool          // skip when nothing changed since the last successful run
+	Force        bool          // run even if IfChanged finds nothing changed
+	Auto         bool          // run from a git hook: quiet, and no setup file is not an error
+	DryRun       bool          // print what would run instead
 }
 
 // RunSetup re-runs the setup script in the current worktree.
 func RunSetup(cfg RunSetupConfig) error {
-	scriptPath := cfg.SetupScript
+	scriptPath, err := ResolveSetupScript(cfg.SetupScript, cfg.MainRepoRoot)
+	if err != nil {
+		return err
+	}
 	if scriptPath == "" {
-		candidate := filepath.Join(cfg.MainRepoRoot, ".wtwrc")
-		if _, err := os.Stat(candidate); err == nil {
-			scriptPath = candidate
+		if cfg.Auto {
+			return nil
 		}
-	} else {
-		if _, err := os.Stat(scriptPath); err != nil {
-			return fmt.Errorf("setup script not found: %s", scriptPath)
+		return errors.New("no " + StepsFileName + " or .wtwrc found")
+	}
+
+	branchName, _ := git.Output("branch", "--show-current")
+	spec := setupSpec{
+		script:       scriptPath,
+		steps:        cfg.Steps,
+		timeout:      cfg.Timeout,
+		interpreter:  cfg.Interpreter,
+		inputs:       cfg.Inputs,
+		worktreePath: cfg.WorktreeRoot,
+		branchName:   branchName,
+		repoRoot:     cfg.MainRepoRoot,
+		originalDir:  cfg.OriginalDir,
+	}
+	if cfg.IfChanged && !cfg.Force {
+		if last, same := setupUnchanged(spec); same {
+			if cfg.DryRun {
+				fmt.Println("Dry run: nothing changed since the last successful setup; it would be skipped.")
+				return nil
+			}
+			if !cfg.Auto {
+				fmt.Printf("Nothing changed since the last successful setup (%s); skipping. Use --force to run it anyway.\n",
+					last.Finished.Local().Format("2006-01-02 15:04"))
+			}
+			return nil
+		}
+		if cfg.Auto {
+			fmt.Println("wtw: setup inputs changed, re-running " + filepath.Base(scriptPath))
 		}
 	}
-	if scriptPath == "" {
-		return errors.New("no .wtwrc found")
+
+	if cfg.DryRun {
+		fmt.Println("Dry run: nothing will be changed.")
+		return printSetupPlan(spec, false)
 	}
 
-	branchName, _ := git.Output("branch", "--show-current")
-	if err := RunScript(scriptPath, cfg.WorktreeRoot, branchName, cfg.MainRepoRoot, cfg.OriginalDir); err != nil {
-		return fmt.Errorf("setup script failed: %w", err)
+	if _, err := ensureTrusted(scriptPath, cfg.MainRepoRoot); err != nil {
+		return err
+	}
+
+	err = runSetupFile(nil, spec)
+	if errors.Is(err, ErrSetupTimedOut) || errors.Is(err, ErrSetupInterrupted) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("setup failed: %w", err)
 	}
 	ui.Success("Done.")
 	return nil
 }
 
-// List prints all worktrees for the current repo, marking the current one.
+// List prints all worktrees for the current repo, marking the current one
+// and those whose last setup run failed.
 func List(repoRoot string) error {
 	worktrees, err := git.ListWorktrees(repoRoot)
 	if err != nil {
@@ -202,10 +318,18 @@ func List(repoRoot string) error {
 		if branch == "" {
 			branch = "(detached)"
 		}
+		line := wt.Path + "  " + branch
+		st, ok, _ := ReadSetupStatus(wt.Path)
+		if ok && st.failed() {
+			line += "  ✗ setup " + st.State + " (wtw logs " + wt.Branch + ")"
+		}
+		if step, incomplete := incompleteStep(wt.Path); incomplete && !(ok && !st.done()) {
+			line += "  ⋯ incomplete at " + step + " (wtw resume " + wt.Branch + ")"
+		}
 		if wt.Path == cwd {
-			ui.PrintCmd(wt.Path + "  " + branch + "  ← current")
+			ui.PrintCmd(line + "  ← current")
 		} else {
-			fmt.Printf("%s  %s\n", wt.Path, branch)
+			fmt.Println(line)
 		}
 	}
 	return nil
@@ -224,98 +348,138 @@ func Init(repoRoot string) error {
 	return nil
 }
 
-// RunScript executes a bash script in worktreePath with the standard env vars.
-func RunScript(scriptPath, worktreePath, branchName, repoRoot, originalDir string) error {
-	cmd := exec.Command("bash", scriptPath)
-	cmd.Dir = worktreePath
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Env = append(os.Environ(),
-		"WORKTREE_PATH="+worktreePath,
-		"WORKTREE_NAME="+filepath.Base(worktreePath),
-		"BRANCH_NAME="+branchName,
-		"REPO_NAME="+filepath.Base(repoRoot),
-		"REPO_ROOT="+repoRoot,
-		"ORIGINAL_DIR="+originalDir,
-	)
-	return cmd.Run()
-}
-
-// EnvSetConfig holds inputs for EnvSet.
-type EnvSetConfig struct {
-	File  string   // path to the env file
-	Pairs []string // KEY=VALUE entries
+// setupSpec describes one run of a setup file.
+type setupSpec struct {
+	script       string
+	steps        StepSelection
+	timeout      time.Duration // 0 means no limit
+	interpreter  string        // overrides the shebang of a single script
+	inputs       []string      // hashed into the run's fingerprint; nil means DefaultSetupInputs
+	worktreePath string
+	branchName   string
+	repoRoot     string
+	originalDir  string
+	done         []string // steps or scripts a resumed creation already ran
 }
 
-// EnvSet sets or adds key-value pairs in an env file.
-// Existing keys are replaced in-place; absent keys are appended at the end.
-// Comment and blank lines are preserved unchanged.
-func EnvSet(cfg EnvSetConfig) error {
-	values := make(map[string]string, len(cfg.Pairs))
-	var order []string
-	for _, p := range cfg.Pairs {
-		idx := strings.IndexByte(p, '=')
-		if idx < 0 {
-			return fmt.Errorf("invalid pair %q: expected KEY=VALUE", p)
-		}
-		key := p[:idx]
-		if _, seen := values[key]; !seen {
-			order = append(order, key)
+// runSetupFile runs a declarative setup file with RunSteps, or a shell
+// script with RunScript. The run is recorded in run, or in a new record
+// when run is nil.
+func runSetupFile(run *setupRun, spec setupSpec) error {
+	if !IsStepsFile(spec.script) && (len(spec.steps.Only) > 0 || len(spec.steps.Skip) > 0) {
+		return errors.New("--only/--skip need a declarative setup file (" + StepsFileName + ")")
+	}
+	env := scriptEnv(spec.worktreePath, spec.branchName, spec.repoRoot, spec.originalDir)
+	if run == nil {
+		var err error
+		if run, err = beginSetupRun(spec.worktreePath, spec.script, false); err != nil {
+			return err
 		}
-		values[key] = p[idx+1:]
 	}
-
-	data, err := os.ReadFile(cfg.File)
-	if err != nil {
-		return fmt.Errorf("cannot read %s: %w", cfg.File, err)
+	if fp, err := setupFingerprint(spec); err == nil {
+		run.status.Fingerprint = fp
+	}
+	if spec.timeout > 0 {
+		run.deadline = time.Now().Add(spec.timeout)
 	}
 
-	content := string(data)
-	trailingNewline := strings.HasSuffix(content, "\n")
-	if trailingNewline {
-		content = content[:len(content)-1]
+	var err error
+	if IsStepsFile(spec.script) {
+		err = runSteps(run, spec, env)
+	} else {
+		err = runScript(run, spec, env)
+	}
+	if errors.Is(err, ErrSetupTimedOut) {
+		err = fmt.Errorf("%w after %s", ErrSetupTimedOut, spec.timeout)
+	}
+	if err == nil {
+		recordSetupFinished(spec)
 	}
+	if ferr := run.finish(err, env); ferr != nil && err == nil {
+		return ferr
+	}
+	return err
+}
 
-	lines := strings.Split(content, "\n")
-	written := make(map[string]bool, len(values))
+// RunScript executes a setup script in worktreePath with the standard env
+// vars, using the interpreter named by its shebang (bash if none). A
+// .wtwrc.d directory runs each of its scripts in turn. Output is teed into a
+// setup log in the worktree's git dir.
+func RunScript(scriptPath, worktreePath, branchName, repoRoot, originalDir string) error {
+	return runSetupFile(nil, setupSpec{
+		script:       scriptPath,
+		worktreePath: worktreePath,
+		branchName:   branchName,
+		repoRoot:     repoRoot,
+		originalDir:  originalDir,
+	})
+}
 
-	for i, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
-			continue
-		}
-		eq := strings.IndexByte(line, '=')
-		if eq < 0 {
+func runScript(run *setupRun, spec setupSpec, env []string) error {
+	if !isSetupDir(spec.script) {
+		return runOneScript(run, spec.script, spec.interpreter, spec.worktreePath, env)
+	}
+	scripts, err := setupDirScripts(spec.script)
+	if err != nil {
+		return err
+	}
+	for _, script := range scripts {
+		name := filepath.Base(script)
+		if slices.Contains(spec.done, name) {
+			fmt.Fprintf(run.stdout, "▸ %s (done)\n", name)
 			continue
 		}
-		key := strings.TrimSpace(line[:eq])
-		if val, ok := values[key]; ok {
-			lines[i] = key + "=" + val
-			written[key] = true
+		fmt.Fprintf(run.stdout, "▸ %s\n", name)
+		if err := runOneScript(run, script, "", spec.worktreePath, env); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
 		}
+		recordSetupProgress(spec, name)
 	}
+	return nil
+}
 
-	for _, key := range order {
-		if !written[key] {
-			lines = append(lines, key+"="+values[key])
-		}
+func runOneScript(run *setupRun, script, interpreter, worktreePath string, env []string) error {
+	argv, err := scriptCommand(script, interpreter)
+	if err != nil {
+		return err
 	}
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Dir = worktreePath
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = run.stdout
+	cmd.Stderr = run.stderr
+	cmd.Env = append(os.Environ(), env...)
+	return runSetupCommand(cmd, run.deadline)
+}
 
-	result := strings.Join(lines, "\n")
-	if trailingNewline || len(lines) > 0 {
-		result += "\n"
-	}
+// WorktreeEnv returns the variables a setup script of the worktree at
+// worktreePath gets, for commands run by hand inside it.
+func WorktreeEnv(worktreePath, repoRoot, originalDir string) []string {
+	return scriptEnv(worktreePath, git.CurrentBranch(worktreePath), repoRoot, originalDir)
+}
 
-	info, err := os.Stat(cfg.File)
-	if err != nil {
-		return err
+// scriptEnv returns the standard variables passed to setup scripts and
+// command templates, as KEY=VALUE entries, including the worktree's ports
+// when it has an allocation.
+func scriptEnv(worktreePath, branchName, repoRoot, originalDir string) []string {
+	env := []string{
+		"WORKTREE_PATH=" + worktreePath,
+		"WORKTREE_NAME=" + filepath.Base(worktreePath),
+		"BRANCH_NAME=" + branchName,
+		"REPO_NAME=" + filepath.Base(repoRoot),
+		"REPO_ROOT=" + repoRoot,
+		"ORIGINAL_DIR=" + originalDir,
+	}
+	if a, ok := ports.Lookup(worktreePath); ok {
+		env = append(env, a.Env()...)
 	}
-	return os.WriteFile(cfg.File, []byte(result), info.Mode())
+	return env
 }
 
 // ResolveSetupScript resolves the effective setup script path.
 // customSetup is the -c flag value (may be ""). repoRoot is the main repo root.
+// Without -c, a declarative .wtw.yml takes precedence over .wtwrc, which
+// takes precedence over a .wtwrc.d directory.
 func ResolveSetupScript(customSetup, repoRoot string) (string, error) {
 	if customSetup != "" {
 		if _, err := os.Stat(customSetup); err != nil {
@@ -323,9 +487,11 @@ func ResolveSetupScript(customSetup, repoRoot string) (string, error) {
 		}
 		return customSetup, nil
 	}
-	rc := filepath.Join(repoRoot, ".wtwrc")
-	if _, err := os.Stat(rc); err == nil {
-		return rc, nil
+	for _, name := range []string{StepsFileName, ".wtwrc", SetupDirName} {
+		rc := filepath.Join(repoRoot, name)
+		if _, err := os.Stat(rc); err == nil {
+			return rc, nil
+		}
 	}
 	return "", nil
 }
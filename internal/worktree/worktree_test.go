@@ -292,6 +292,21 @@ func TestEnvSet_ValueContainsEquals(t *testing.T) {
 	}
 }
 
+func TestEnvSet_KeepsExportAndQuoting(t *testing.T) {
+	dir := t.TempDir()
+	path := writeEnvFile(t, dir, "export APP_NAME='old' # shown in the title\nSECRET=\"a # b\"\n")
+
+	if err := EnvSet(EnvSetConfig{File: path, Pairs: []string{"APP_NAME=My App", "GREETING=hello world"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	got := readEnvFile(t, path)
+	want := "export APP_NAME='My App' # shown in the title\nSECRET=\"a # b\"\nGREETING=\"hello world\"\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
 func TestEnvSet_MissingFile(t *testing.T) {
 	err := EnvSet(EnvSetConfig{File: "/nonexistent/.env", Pairs: []string{"K=V"}})
 	if err == nil {